@@ -0,0 +1,437 @@
+// Package agwpe provides a transport for connecting to a JNOS BBS through a
+// soundcard modem program (e.g., AGWPE, SoundModem, UZ7HO, or Direwolf) that
+// speaks the AGWPE TCP/IP API.  Unlike KISS, the AGWPE API hands AX.25
+// connection management to the modem program, so this package only needs to
+// ask for the connection and then shuttle data over it.
+package agwpe
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rothskeller/packet/jnos"
+)
+
+// DefaultPort is the default TCP port for the AGWPE API.
+const DefaultPort = 8000
+
+// headerLen is the length of an AGWPE frame header.
+const headerLen = 36
+
+// AGWPE frame kinds used by this package.
+const (
+	kindRegister    = 'X'
+	kindUnregister  = 'x'
+	kindConnect     = 'C'
+	kindData        = 'D'
+	kindDisconnect  = 'd'
+	kindOutstanding = 'Y'
+	kindUnproto     = 'M'
+)
+
+// pidNone is the AX.25 protocol ID for "no layer 3 protocol".
+const pidNone = 0xF0
+
+// drainTimeout is how long Close will wait for sent data to be acknowledged
+// before disconnecting anyway.
+const drainTimeout = 2 * time.Minute
+
+// registerTimeout is how long to wait for the AGWPE server to answer our
+// registration request.
+const registerTimeout = 30 * time.Second
+
+// connectTimeout is how long to wait for the AGWPE server to report that the
+// connection to the BBS has been made (or has failed).  AGWPE servers normally
+// report failure themselves after exhausting their retries, well before this.
+const connectTimeout = 3 * time.Minute
+
+// A frame is an AGWPE API frame.
+type frame struct {
+	port     byte
+	kind     byte
+	pid      byte
+	callFrom string
+	callTo   string
+	data     []byte
+}
+
+// encode returns the encoded form of the frame.
+func (f *frame) encode() []byte {
+	var buf = make([]byte, headerLen, headerLen+len(f.data))
+
+	buf[0] = f.port
+	buf[4] = f.kind
+	buf[6] = f.pid
+	copy(buf[8:17], f.callFrom)
+	copy(buf[18:27], f.callTo)
+	binary.LittleEndian.PutUint32(buf[28:32], uint32(len(f.data)))
+	return append(buf, f.data...)
+}
+
+// readFrame reads a frame from the AGWPE server.
+func readFrame(r io.Reader) (f *frame, err error) {
+	var header [headerLen]byte
+
+	if _, err = io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	f = &frame{
+		port:     header[0],
+		kind:     header[4],
+		pid:      header[6],
+		callFrom: cString(header[8:18]),
+		callTo:   cString(header[18:28]),
+	}
+	if dlen := binary.LittleEndian.Uint32(header[28:32]); dlen > 0 {
+		if dlen > 65536 {
+			return nil, fmt.Errorf("invalid AGWPE frame length %d", dlen)
+		}
+		f.data = make([]byte, dlen)
+		if _, err = io.ReadFull(r, f.data); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// cString returns the NUL-terminated string in b.
+func cString(b []byte) string {
+	if idx := strings.IndexByte(string(b), 0); idx >= 0 {
+		b = b[:idx]
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// conn is a connection to the BBS through the AGWPE server.  It implements
+// io.ReadWriteCloser, presenting the connection as a byte stream.
+type conn struct {
+	nc     net.Conn
+	port   byte
+	local  string
+	remote string
+	opcall string
+	log    io.Writer
+	wmu    sync.Mutex
+	done   chan struct{}
+	// regTimeout and connTimeout are normally registerTimeout and
+	// connectTimeout; tests shorten them.
+	regTimeout  time.Duration
+	connTimeout time.Duration
+
+	mu          sync.Mutex
+	cond        *sync.Cond
+	registered  bool
+	connected   bool
+	closing     bool
+	outstanding int
+	rbuf        []byte
+	err         error
+}
+
+// Connect opens a connection to the BBS at the specified AX.25 address, through
+// the AGWPE server at the specified TCP host:port, using the specified radio
+// port (numbered from zero) of that server.  The connection is made from the
+// specified mailbox call sign.  If that is not the same as the operator's FCC
+// call sign, the FCC call sign is sent as an identification frame when the
+// connection is closed.  The log of the connection, including the AGWPE frames
+// exchanged other than data frames, is written to log.
+func Connect(agwaddr string, radioport int, remote, mailbox, opcall string, log io.Writer) (c *jnos.Conn, err error) {
+	var (
+		ac *conn
+		nc net.Conn
+	)
+	if len(mailbox) > 9 || len(remote) > 9 {
+		return nil, errors.New("call sign too long")
+	}
+	if nc, err = net.Dial("tcp", agwaddr); err != nil {
+		return nil, fmt.Errorf("connect to %s: %s", agwaddr, err)
+	}
+	ac = newConn(nc, radioport, remote, mailbox, opcall, log)
+	if err = ac.connect(); err != nil {
+		ac.shutdown()
+		return nil, err
+	}
+	if c, err = jnos.Connect(ac, log); err != nil {
+		ac.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// newConn returns a conn using the supplied connection to the AGWPE server,
+// and starts receiving frames from it.
+func newConn(nc net.Conn, radioport int, remote, mailbox, opcall string, log io.Writer) (c *conn) {
+	c = &conn{
+		nc:          nc,
+		port:        byte(radioport),
+		local:       strings.ToUpper(mailbox),
+		remote:      strings.ToUpper(remote),
+		opcall:      strings.ToUpper(opcall),
+		log:         log,
+		done:        make(chan struct{}),
+		regTimeout:  registerTimeout,
+		connTimeout: connectTimeout,
+	}
+	c.cond = sync.NewCond(&c.mu)
+	go c.receiver()
+	return c
+}
+
+// connect registers our call sign with the AGWPE server and asks it to connect
+// to the BBS.
+func (c *conn) connect() (err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err = c.send(&frame{kind: kindRegister, callFrom: c.local}); err != nil {
+		return err
+	}
+	if !c.waitFor(func() bool { return c.registered }, c.regTimeout) {
+		return fmt.Errorf("no response from AGWPE server to registration of %s", c.local)
+	}
+	if c.err != nil {
+		return c.err
+	}
+	if err = c.send(&frame{port: c.port, kind: kindConnect, pid: pidNone, callFrom: c.local, callTo: c.remote}); err != nil {
+		return err
+	}
+	if !c.waitFor(func() bool { return c.connected }, c.connTimeout) {
+		// Cancel the connection attempt.
+		c.closing = true
+		c.send(&frame{port: c.port, kind: kindDisconnect, callFrom: c.local, callTo: c.remote})
+		return fmt.Errorf("timed out connecting to %s", c.remote)
+	}
+	return c.err
+}
+
+// waitFor waits until done returns true or the connection fails.  It returns
+// false if neither happens within the specified timeout.  It is called with the
+// lock held.
+func (c *conn) waitFor(done func() bool, timeout time.Duration) bool {
+	// The timer wakes us at the deadline in case the server never
+	// answers.
+	deadline := time.Now().Add(timeout)
+	timer := time.AfterFunc(timeout, func() {
+		c.mu.Lock()
+		c.cond.Broadcast()
+		c.mu.Unlock()
+	})
+	defer timer.Stop()
+	for !done() && c.err == nil {
+		if !time.Now().Before(deadline) {
+			return false
+		}
+		c.cond.Wait()
+	}
+	return true
+}
+
+// Read reads data received from the BBS.
+func (c *conn) Read(p []byte) (n int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.rbuf) == 0 && c.err == nil {
+		c.cond.Wait()
+	}
+	if len(c.rbuf) != 0 {
+		n = copy(p, c.rbuf)
+		c.rbuf = c.rbuf[n:]
+		return n, nil
+	}
+	return 0, c.err
+}
+
+// Write sends data to the BBS.
+func (c *conn) Write(p []byte) (n int, err error) {
+	c.mu.Lock()
+	err = c.err
+	c.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	err = c.send(&frame{port: c.port, kind: kindData, pid: pidNone, callFrom: c.local, callTo: c.remote, data: p})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close waits for sent data to be acknowledged, disconnects from the BBS, and
+// closes the connection to the AGWPE server.
+func (c *conn) Close() (err error) {
+	c.mu.Lock()
+	if c.connected {
+		// Wait for the AGWPE server to report that all of our data
+		// has been acknowledged by the BBS.
+		// The timer wakes us at the deadline in case the server never
+		// answers our query.
+		deadline := time.Now().Add(drainTimeout)
+		timer := time.AfterFunc(drainTimeout, func() {
+			c.mu.Lock()
+			c.cond.Broadcast()
+			c.mu.Unlock()
+		})
+		for c.connected && c.err == nil && time.Now().Before(deadline) {
+			c.outstanding = -1
+			c.send(&frame{port: c.port, kind: kindOutstanding, callFrom: c.local, callTo: c.remote})
+			for c.outstanding < 0 && c.connected && c.err == nil && time.Now().Before(deadline) {
+				c.cond.Wait()
+			}
+			if c.outstanding <= 0 {
+				break
+			}
+			c.mu.Unlock()
+			time.Sleep(time.Second)
+			c.mu.Lock()
+		}
+		timer.Stop()
+	}
+	if c.connected {
+		c.closing = true
+		c.send(&frame{port: c.port, kind: kindDisconnect, callFrom: c.local, callTo: c.remote})
+		for c.connected && (c.err == nil || c.err == io.EOF) {
+			c.cond.Wait()
+		}
+	}
+	if c.err != nil && c.err != io.EOF {
+		err = c.err
+	}
+	c.mu.Unlock()
+	c.shutdown()
+	return err
+}
+
+// shutdown sends station identification if needed, unregisters our call sign,
+// and closes the connection to the AGWPE server.
+func (c *conn) shutdown() {
+	c.mu.Lock()
+	if c.registered {
+		if c.opcall != "" && c.opcall != c.local {
+			c.send(&frame{port: c.port, kind: kindUnproto, pid: pidNone, callFrom: c.local, callTo: "ID", data: []byte(c.opcall)})
+		}
+		c.send(&frame{kind: kindUnregister, callFrom: c.local})
+		c.registered = false
+	}
+	c.closing = true
+	c.mu.Unlock()
+	c.nc.Close()
+	<-c.done
+}
+
+// receiver runs in a separate goroutine, reading frames from the AGWPE server
+// and handling them.
+func (c *conn) receiver() {
+	defer close(c.done)
+	for {
+		f, err := readFrame(c.nc)
+		c.mu.Lock()
+		if err != nil {
+			if !c.closing {
+				c.fail(fmt.Errorf("AGWPE read: %s", err))
+			} else if c.err == nil {
+				c.err = io.EOF
+			}
+			c.connected = false
+			c.cond.Broadcast()
+			c.mu.Unlock()
+			return
+		}
+		c.receive(f)
+		c.mu.Unlock()
+	}
+}
+
+// receive handles a single received frame.  It is called with the lock held.
+func (c *conn) receive(f *frame) {
+	if f.kind != kindData {
+		c.logFrame("<", f)
+	}
+	switch f.kind {
+	case kindRegister:
+		if len(f.data) > 0 && f.data[0] == 1 {
+			c.registered = true
+		} else {
+			c.fail(fmt.Errorf("AGWPE server refused registration of %s", c.local))
+		}
+	case kindConnect:
+		if f.port == c.port && strings.EqualFold(f.callFrom, c.remote) {
+			c.connected = true
+		}
+	case kindData:
+		if f.port == c.port && strings.EqualFold(f.callFrom, c.remote) {
+			c.rbuf = append(c.rbuf, f.data...)
+		}
+	case kindOutstanding:
+		if f.port == c.port && strings.EqualFold(f.callTo, c.remote) && len(f.data) >= 4 {
+			c.outstanding = int(binary.LittleEndian.Uint32(f.data))
+		}
+	case kindDisconnect:
+		if f.port != c.port || !strings.EqualFold(f.callFrom, c.remote) {
+			break
+		}
+		if !c.connected && !c.closing {
+			c.fail(fmt.Errorf("connection to %s failed: %s", c.remote, strings.TrimSpace(string(f.data))))
+		} else if !c.closing {
+			c.fail(fmt.Errorf("disconnected by %s", c.remote))
+		} else if c.err == nil {
+			c.err = io.EOF
+		}
+		c.connected = false
+	}
+	c.cond.Broadcast()
+}
+
+// fail records an error on the connection.  It is called with the lock held.
+func (c *conn) fail(err error) {
+	if c.err == nil {
+		c.err = err
+	}
+	c.cond.Broadcast()
+}
+
+// send sends a frame to the AGWPE server.
+func (c *conn) send(f *frame) error {
+	if f.kind != kindData {
+		c.logFrame(">", f)
+	}
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if _, err := c.nc.Write(f.encode()); err != nil {
+		return fmt.Errorf("AGWPE write: %s", err)
+	}
+	return nil
+}
+
+// logFrame writes a description of a frame to the log.
+func (c *conn) logFrame(dir string, f *frame) {
+	if c.log == nil {
+		return
+	}
+	var desc = fmt.Sprintf("[AGWPE %s %c port=%d", dir, f.kind, f.port)
+	if f.callFrom != "" {
+		desc += " from=" + f.callFrom
+	}
+	if f.callTo != "" {
+		desc += " to=" + f.callTo
+	}
+	switch f.kind {
+	case kindRegister:
+		if len(f.data) != 0 {
+			desc += fmt.Sprintf(" result=%d", f.data[0])
+		}
+	case kindOutstanding:
+		if len(f.data) >= 4 {
+			desc += fmt.Sprintf(" count=%d", binary.LittleEndian.Uint32(f.data))
+		}
+	default:
+		if text := strings.TrimSpace(strings.Trim(string(f.data), "\x00")); text != "" {
+			desc += " " + text
+		}
+	}
+	fmt.Fprintln(c.log, desc+"]")
+}
@@ -0,0 +1,296 @@
+package agwpe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// header returns an encoded AGWPE frame header.
+func header(port, kind, pid byte, from, to string, dlen uint32) []byte {
+	var h = make([]byte, headerLen)
+
+	h[0], h[4], h[6] = port, kind, pid
+	copy(h[8:], from)
+	copy(h[18:], to)
+	binary.LittleEndian.PutUint32(h[28:], dlen)
+	return h
+}
+
+func TestFrameEncode(t *testing.T) {
+	tests := []struct {
+		name string
+		f    frame
+		want []byte
+	}{
+		{"register", frame{kind: kindRegister, callFrom: "KC6RSC"}, header(0, 'X', 0, "KC6RSC", "", 0)},
+		{"connect", frame{port: 1, kind: kindConnect, pid: pidNone, callFrom: "KC6RSC", callTo: "W6XSC-1"}, header(1, 'C', 0xF0, "KC6RSC", "W6XSC-1", 0)},
+		{"data", frame{kind: kindData, pid: pidNone, callFrom: "KC6RSC", callTo: "W6XSC-1", data: []byte("B\r")}, append(header(0, 'D', 0xF0, "KC6RSC", "W6XSC-1", 2), 'B', '\r')},
+		{"long calls", frame{kind: kindData, callFrom: "KC6RSC-15", callTo: "W6XSC-15"}, header(0, 'D', 0, "KC6RSC-15", "W6XSC-15", 0)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.f.encode(); !bytes.Equal(got, tt.want) {
+				t.Errorf("encode = % X, want % X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		want  *frame
+		err   bool
+	}{
+		{"register reply", append(header(0, 'X', 0, "KC6RSC", "", 1), 1), &frame{kind: 'X', callFrom: "KC6RSC", data: []byte{1}}, false},
+		{"connected", append(header(1, 'C', 0xF0, "W6XSC-1", "KC6RSC", 3), "ok\x00"...), &frame{port: 1, kind: 'C', pid: 0xF0, callFrom: "W6XSC-1", callTo: "KC6RSC", data: []byte("ok\x00")}, false},
+		{"padded calls", header(0, 'd', 0, "W6XSC-1 \x00junk", "KC6RSC", 0), &frame{kind: 'd', callFrom: "W6XSC-1", callTo: "KC6RSC"}, false},
+		{"unterminated calls", header(0, 'Y', 0, "KC6RSC-15", "W6XSC-15\x00", 0), &frame{kind: 'Y', callFrom: "KC6RSC-15", callTo: "W6XSC-15"}, false},
+		{"short header", header(0, 'X', 0, "KC6RSC", "", 0)[:20], nil, true},
+		{"short data", append(header(0, 'D', 0, "W6XSC-1", "KC6RSC", 10), "abc"...), nil, true},
+		{"too long", header(0, 'D', 0, "W6XSC-1", "KC6RSC", 65537), nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readFrame(bytes.NewReader(tt.input))
+			if tt.err {
+				if err == nil {
+					t.Errorf("readFrame = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("readFrame: %s", err)
+			}
+			if got.port != tt.want.port || got.kind != tt.want.kind || got.pid != tt.want.pid || got.callFrom != tt.want.callFrom || got.callTo != tt.want.callTo || !bytes.Equal(got.data, tt.want.data) {
+				t.Errorf("readFrame = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// The rest of the tests run a conn against a stand-in AGWPE server on the
+// other end of a net.Pipe.
+
+const (
+	testBBS  = "W6XSC-1"
+	testHost = "KC6RSC"
+)
+
+// A testServer is the AGWPE server end of a conn under test.
+type testServer struct {
+	t      *testing.T
+	conn   net.Conn
+	frames chan *frame
+}
+
+// newTestConn returns a conn to a test server.
+func newTestConn(t *testing.T) (*conn, *testServer) {
+	var hostEnd, serverEnd = net.Pipe()
+
+	c := newConn(hostEnd, 0, testBBS, testHost, testHost, nil)
+	s := &testServer{t: t, conn: serverEnd, frames: make(chan *frame, 64)}
+	// Read frames continuously, so that the conn never blocks writing to
+	// the pipe.
+	go func() {
+		defer close(s.frames)
+		for {
+			f, err := readFrame(serverEnd)
+			if err != nil {
+				return
+			}
+			s.frames <- f
+		}
+	}()
+	t.Cleanup(func() { serverEnd.Close() })
+	return c, s
+}
+
+// connectedTestConn returns a test conn that has been registered and
+// connected.
+func connectedTestConn(t *testing.T) (*conn, *testServer) {
+	var errch = make(chan error)
+
+	c, s := newTestConn(t)
+	go func() { errch <- c.connect() }()
+	s.expect(kindRegister)
+	s.send(&frame{kind: kindRegister, callFrom: testHost, data: []byte{1}})
+	s.expect(kindConnect)
+	s.send(&frame{kind: kindConnect, callFrom: testBBS, callTo: testHost, data: []byte("*** CONNECTED With " + testBBS + "\r")})
+	if err := <-errch; err != nil {
+		t.Fatalf("connect: %s", err)
+	}
+	return c, s
+}
+
+// expect waits for the next frame from the conn, and verifies its kind.
+func (s *testServer) expect(kind byte) *frame {
+	s.t.Helper()
+	select {
+	case f, ok := <-s.frames:
+		if !ok {
+			s.t.Fatalf("got EOF, want frame %c", kind)
+		}
+		if f.kind != kind {
+			s.t.Fatalf("got frame %c, want %c", f.kind, kind)
+		}
+		return f
+	case <-time.After(5 * time.Second):
+		s.t.Fatalf("timed out waiting for frame %c", kind)
+	}
+	return nil
+}
+
+// send sends a frame to the conn.
+func (s *testServer) send(f *frame) {
+	s.t.Helper()
+	if _, err := s.conn.Write(f.encode()); err != nil {
+		s.t.Fatalf("send: %s", err)
+	}
+}
+
+// connectError runs connect on the conn, and returns the error it fails with
+// (or nil).
+func connectError(t *testing.T, c *conn, script func()) error {
+	var errch = make(chan error)
+
+	go func() { errch <- c.connect() }()
+	script()
+	select {
+	case err := <-errch:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for connect")
+	}
+	return nil
+}
+
+func TestConnectAndClose(t *testing.T) {
+	var errch = make(chan error)
+
+	c, s := connectedTestConn(t)
+	go func() { errch <- c.Close() }()
+	// Close asks how much data is outstanding before disconnecting.
+	f := s.expect(kindOutstanding)
+	if f.callFrom != testHost || f.callTo != testBBS {
+		t.Errorf("Y frame from %s to %s, want from %s to %s", f.callFrom, f.callTo, testHost, testBBS)
+	}
+	s.send(&frame{kind: kindOutstanding, callFrom: testHost, callTo: testBBS, data: []byte{0, 0, 0, 0}})
+	s.expect(kindDisconnect)
+	s.send(&frame{kind: kindDisconnect, callFrom: testBBS, callTo: testHost, data: []byte("*** DISCONNECTED From " + testBBS + "\r")})
+	s.expect(kindUnregister)
+	if err := <-errch; err != nil {
+		t.Errorf("Close: %s", err)
+	}
+}
+
+func TestCloseWaitsForOutstanding(t *testing.T) {
+	var errch = make(chan error)
+
+	c, s := connectedTestConn(t)
+	go func() { errch <- c.Close() }()
+	s.expect(kindOutstanding)
+	s.send(&frame{kind: kindOutstanding, callFrom: testHost, callTo: testBBS, data: []byte{2, 0, 0, 0}})
+	// Close asks again after a pause.
+	s.expect(kindOutstanding)
+	s.send(&frame{kind: kindOutstanding, callFrom: testHost, callTo: testBBS, data: []byte{0, 0, 0, 0}})
+	s.expect(kindDisconnect)
+	s.send(&frame{kind: kindDisconnect, callFrom: testBBS, callTo: testHost})
+	s.expect(kindUnregister)
+	if err := <-errch; err != nil {
+		t.Errorf("Close: %s", err)
+	}
+}
+
+func TestRegisterRefused(t *testing.T) {
+	c, s := newTestConn(t)
+	err := connectError(t, c, func() {
+		s.expect(kindRegister)
+		s.send(&frame{kind: kindRegister, callFrom: testHost, data: []byte{0}})
+	})
+	if err == nil || !strings.Contains(err.Error(), "refused registration") {
+		t.Errorf("connect error %v, want refused registration", err)
+	}
+	c.shutdown()
+}
+
+func TestRegisterTimeout(t *testing.T) {
+	c, s := newTestConn(t)
+	c.regTimeout = 50 * time.Millisecond
+	err := connectError(t, c, func() { s.expect(kindRegister) })
+	if err == nil || !strings.Contains(err.Error(), "no response from AGWPE server") {
+		t.Errorf("connect error %v, want no response", err)
+	}
+	c.shutdown()
+}
+
+func TestConnectFailed(t *testing.T) {
+	c, s := newTestConn(t)
+	err := connectError(t, c, func() {
+		s.expect(kindRegister)
+		s.send(&frame{kind: kindRegister, callFrom: testHost, data: []byte{1}})
+		s.expect(kindConnect)
+		s.send(&frame{kind: kindDisconnect, callFrom: testBBS, callTo: testHost, data: []byte("*** DISCONNECTED RETRYOUT With " + testBBS + "\r")})
+	})
+	if err == nil || !strings.Contains(err.Error(), "RETRYOUT") {
+		t.Errorf("connect error %v, want RETRYOUT", err)
+	}
+	c.shutdown()
+}
+
+func TestConnectTimeout(t *testing.T) {
+	c, s := newTestConn(t)
+	c.connTimeout = 50 * time.Millisecond
+	err := connectError(t, c, func() {
+		s.expect(kindRegister)
+		s.send(&frame{kind: kindRegister, callFrom: testHost, data: []byte{1}})
+		s.expect(kindConnect)
+		// The abandoned connection attempt is cancelled.
+		s.expect(kindDisconnect)
+	})
+	if err == nil || !strings.Contains(err.Error(), "timed out connecting to "+testBBS) {
+		t.Errorf("connect error %v, want timed out", err)
+	}
+	c.shutdown()
+}
+
+func TestReceiveData(t *testing.T) {
+	var buf = make([]byte, 64)
+
+	c, s := connectedTestConn(t)
+	// Data from other stations or radio ports is ignored.
+	s.send(&frame{port: 1, kind: kindData, callFrom: testBBS, callTo: testHost, data: []byte("other port")})
+	s.send(&frame{kind: kindData, callFrom: "W6XSC-2", callTo: testHost, data: []byte("other station")})
+	s.send(&frame{kind: kindData, callFrom: testBBS, callTo: testHost, data: []byte("[JNOS-2.0-B1FHIM$]\r")})
+	n, err := c.Read(buf)
+	if err != nil {
+		t.Fatalf("Read: %s", err)
+	}
+	if got := string(buf[:n]); got != "[JNOS-2.0-B1FHIM$]\r" {
+		t.Errorf("Read = %q", got)
+	}
+	// A disconnect we didn't ask for fails the connection.
+	s.send(&frame{kind: kindDisconnect, callFrom: testBBS, callTo: testHost})
+	if _, err = c.Read(buf); err == nil || err == io.EOF || !strings.Contains(err.Error(), "disconnected by "+testBBS) {
+		t.Errorf("Read after disconnect: %v", err)
+	}
+	c.shutdown()
+}
+
+func TestWriteData(t *testing.T) {
+	c, s := connectedTestConn(t)
+	if _, err := c.Write([]byte("B\r")); err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+	f := s.expect(kindData)
+	if f.callFrom != testHost || f.callTo != testBBS || f.pid != pidNone || string(f.data) != "B\r" {
+		t.Errorf("data frame %+v", f)
+	}
+	c.shutdown()
+}
@@ -0,0 +1,94 @@
+package cio
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// alertBellInterval is how often the terminal bell is rung while the shell is
+// waiting for a command and there are unacknowledged immediate messages.
+const alertBellInterval = 15 * time.Second
+
+// alerts is the list of local message IDs of immediate messages that have been
+// received but not yet acknowledged by the operator.
+var alerts []string
+
+// AlertImmediate puts the shell into attention mode for a newly received
+// immediate message:  it rings the terminal bell and displays a banner.  The
+// banner is displayed again above every shell prompt, and the bell is rung
+// periodically while the shell is waiting for a command, until the message is
+// acknowledged.
+func AlertImmediate(lmi string) {
+	if !slices.Contains(alerts, lmi) {
+		alerts = append(alerts, lmi)
+	}
+	if !OutputIsTerm {
+		return
+	}
+	clearStatus()
+	io.WriteString(os.Stdout, "\a")
+	print(colorAlertBG, bannerLine(fmt.Sprintf("IMMEDIATE MESSAGE %s RECEIVED", lmi)))
+	print(0, "\n")
+}
+
+// AcknowledgeAlerts acknowledges the immediate messages with the specified
+// local message IDs, or all of them if none are specified.  It returns the
+// number of messages acknowledged.
+func AcknowledgeAlerts(lmis ...string) (count int) {
+	if len(lmis) == 0 {
+		count, alerts = len(alerts), nil
+		return count
+	}
+	alerts = slices.DeleteFunc(alerts, func(lmi string) bool {
+		if slices.Contains(lmis, lmi) {
+			count++
+			return true
+		}
+		return false
+	})
+	return count
+}
+
+// alertBanner returns the text of the banner shown above the shell prompt
+// while there are unacknowledged immediate messages.
+func alertBanner() string {
+	var what = "MESSAGE"
+
+	if len(alerts) > 1 {
+		what = "MESSAGES"
+	}
+	return bannerLine(fmt.Sprintf("UNACKNOWLEDGED IMMEDIATE %s: %s  (\"show\" or \"ack\" to acknowledge)",
+		what, strings.Join(alerts, ", ")))
+}
+
+// bannerLine returns the supplied text centered in a full-width banner line.
+func bannerLine(s string) string {
+	var width = Width - 1
+
+	s = "*** " + s + " ***"
+	if len(s) < width {
+		s = spaces[:(width-len(s))/2] + s
+	}
+	return setLength(s, width)
+}
+
+// ringAlertBell rings the terminal bell periodically until stop is closed.
+func ringAlertBell(stop chan struct{}) {
+	var ticker = time.NewTicker(alertBellInterval)
+
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			// A BEL is harmless even in the middle of an escape
+			// sequence being written by the editor.
+			io.WriteString(os.Stdout, "\a")
+		}
+	}
+}
@@ -14,7 +14,9 @@ import (
 )
 
 func BulletinScheduleTable() {
-	if OutputIsTerm {
+	if OutputJSON {
+		bulletinsJSON()
+	} else if OutputIsTerm {
 		bulletinsTable()
 	} else {
 		bulletinsCSV()
@@ -44,6 +46,20 @@ func bulletinsCSV() {
 	cw.Flush()
 }
 
+func bulletinsJSON() {
+	var areas = maps.Keys(config.C.Bulletins)
+
+	sort.Strings(areas)
+	for _, area := range areas {
+		bc := config.C.Bulletins[area]
+		writeJSON(os.Stdout, map[string]any{
+			"area":             area,
+			"frequencyMinutes": int(bc.Frequency / time.Minute),
+			"lastCheck":        jsonTime(bc.LastCheck),
+		})
+	}
+}
+
 func bulletinsTable() {
 	var (
 		col1  = []string{"AREA"}
@@ -18,6 +18,32 @@ var (
 	Width int
 )
 
+// Values for ScriptMode.
+const (
+	ScriptAuto = iota // detect whether standard input and output are terminals
+	ScriptOn          // treat neither as a terminal (--script)
+	ScriptOff         // treat both as terminals (--no-script)
+)
+
+// ScriptMode overrides the detection of whether standard input and output are
+// terminals.  It is set by the --script and --no-script global options or the
+// PACKET_SCRIPT environment variable.
+var ScriptMode int
+
+// applyOverrides applies ScriptMode and OutputJSON to the results of terminal
+// detection.
+func applyOverrides() {
+	switch ScriptMode {
+	case ScriptOn:
+		InputIsTerm, OutputIsTerm = false, false
+	case ScriptOff:
+		InputIsTerm, OutputIsTerm = true, true
+	}
+	if OutputJSON {
+		OutputIsTerm = false
+	}
+}
+
 var spaces = "                                                                                                                                                                                                                                                                                                            "
 
 // setLength returns the input string, truncated or right-padded to be exactly
@@ -40,6 +40,7 @@ func Detect() {
 			Width = 80
 		}
 	}
+	applyOverrides()
 }
 
 func rawMode() {
@@ -47,5 +48,7 @@ func rawMode() {
 }
 
 func restoreTerminal() {
-	term.Restore(int(os.Stdin.Fd()), initialStateIn)
+	if initialStateIn != nil { // nil if input is forced to be a terminal
+		term.Restore(int(os.Stdin.Fd()), initialStateIn)
+	}
 }
@@ -46,6 +46,7 @@ func Detect() {
 			Width = 80
 		}
 	}
+	applyOverrides()
 }
 
 func rawMode() {
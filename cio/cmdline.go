@@ -3,8 +3,10 @@ package cio
 import (
 	"bufio"
 	"errors"
+	"fmt"
 	"io"
 	"os"
+	"strings"
 )
 
 var history []string
@@ -23,7 +25,17 @@ func readCommandTerminal() (line string, err error) {
 		selstart     int
 		selend       int
 		historyIndex = len(history)
+		promptX      = 8 // len("packet> ")
+		promptY      int
+		comp         completion
+		lastKey      byte
+		searching    bool   // in Ctrl-R search mode
+		searchFor    string // text being searched for
+		searchIndex  int    // index in history of current match
+		searchFailed bool   // last search found nothing
+		searchSave   string // line before search started
 	)
+	comp.reset()
 	history = append(history, "")
 	rawMode()
 	cleanTerminal()
@@ -32,16 +44,88 @@ func readCommandTerminal() (line string, err error) {
 		cleanTerminal()
 		restoreTerminal()
 	}()
+	// If there are unacknowledged immediate messages, the prompt is
+	// preceded by a banner listing them, and includes a count of them.
+	// Also, the bell is rung periodically while we wait.
+	if len(alerts) != 0 {
+		var stop = make(chan struct{})
+
+		io.WriteString(os.Stdout, "\a")
+		go ringAlertBell(stop)
+		defer close(stop)
+		promptX += len(fmt.Sprintf(" [%d IMM]", len(alerts)))
+		promptY = 1
+	}
 	for {
 		var buf = newScreenBuf(Width - 1)
-		buf.writeAt(0, 0, colorLabel, "packet>")
+		if len(alerts) != 0 {
+			buf.writeAt(0, 0, colorAlertBG, alertBanner())
+			buf.writeAt(0, 1, colorLabel, "packet ")
+			buf.write(colorAlertBG, fmt.Sprintf("[%d IMM]", len(alerts)))
+			buf.write(colorLabel, ">")
+		} else {
+			buf.writeAt(0, 0, colorLabel, "packet>")
+		}
 		pre, sel, post := splitOnSelect(line[scroll:], selstart-scroll, selend-scroll)
-		buf.writeAt(8, 0, 0, pre)
+		buf.writeAt(promptX, promptY, 0, pre)
 		buf.write(colorSelected, sel)
 		buf.write(0, post)
+		comp.show(buf, promptY+1)
+		if searching {
+			buf.writeAt(0, promptY+1, colorLabel, "reverse search: ")
+			buf.write(0, searchFor)
+			if searchFailed {
+				buf.write(colorError, "  (not found)")
+			}
+		}
 		paintBuf(buf)
-		move(8+cursor-scroll, 0)
-		switch key := readKey(); key {
+		if searching {
+			move(16+len(searchFor), promptY+1) // len("reverse search: ")
+		} else {
+			move(promptX+cursor-scroll, promptY)
+		}
+		key := readKey()
+		if searching {
+			// In search mode, printable characters, Backspace, and
+			// Ctrl-R refine the search; Ctrl-G and Esc cancel it;
+			// and any other key accepts the matching line and is
+			// then handled normally.
+			var from = searchIndex
+
+			switch {
+			case key == 0x12: // Ctrl-R
+				from--
+			case key == 0x08 || key == 0x7f: // Backspace
+				if searchFor != "" {
+					searchFor = searchFor[:len(searchFor)-1]
+				}
+				from = len(history) - 2
+			case key >= 0x20 && key <= 0x7e:
+				searchFor += string(key)
+			case key == 0x07 || key == 0x1b: // Ctrl-G, Esc
+				searching, line = false, searchSave
+				cursor = len(line)
+				selstart, selend = cursor, cursor
+				continue
+			default:
+				searching = false
+				unreadKey(key)
+				continue
+			}
+			if idx := searchHistory(searchFor, from); idx >= 0 {
+				searchIndex, searchFailed = idx, false
+				line = history[idx]
+				cursor = strings.Index(line, searchFor)
+			} else {
+				searchFailed = true
+				io.WriteString(os.Stdout, "\a")
+			}
+			selstart, selend = cursor, cursor
+			scroll = min(scroll, cursor)
+			scroll = max(scroll, cursor-Width+promptX+1)
+			continue
+		}
+		switch key {
 		case 0:
 			return "", errors.New("error reading stdin")
 		case 0x01, keyHome: // Ctrl-A
@@ -120,9 +204,12 @@ func readCommandTerminal() (line string, err error) {
 				cursor--
 			}
 			selstart, selend = cursor, cursor
+		case 0x09: // Tab
+			line, cursor = comp.complete(line, cursor, lastKey == 0x09)
+			selstart, selend = cursor, cursor
 		case 0x0A, 0x0D: // Enter
 			history[len(history)-1] = line
-			move(8, 0)
+			move(promptX, promptY)
 			clearToEOL()
 			print(0, line) // might wrap, but that's OK
 			print(0, "\n")
@@ -148,6 +235,9 @@ func readCommandTerminal() (line string, err error) {
 				cursor = len(line)
 				selstart, selend = cursor, cursor
 			}
+		case 0x12: // Ctrl-R
+			searching, searchFor, searchFailed, searchSave = true, "", false, line
+			searchIndex = len(history) - 1
 		case 0x15: // Ctrl-U
 			line = ""
 			selstart, selend, cursor = 0, 0, 0
@@ -158,10 +248,26 @@ func readCommandTerminal() (line string, err error) {
 				selstart, selend = cursor, cursor
 			}
 		}
+		if key != 0x09 {
+			comp.reset()
+		}
+		lastKey = key
 		// Change the scrolling if needed to keep the cursor in view.
 		scroll = min(scroll, cursor)
-		scroll = max(scroll, cursor-Width+9)
+		scroll = max(scroll, cursor-Width+promptX+1)
+	}
+}
+
+// searchHistory returns the index of the most recent command in the history,
+// at or before index from, that contains the search string.  It returns -1 if
+// there is none.
+func searchHistory(s string, from int) int {
+	for i := min(from, len(history)-2); i >= 0; i-- {
+		if strings.Contains(history[i], s) {
+			return i
+		}
 	}
+	return -1
 }
 
 func readCommandStdin() (string, error) {
@@ -0,0 +1,107 @@
+package cio
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// maxCompletionRows is the maximum number of rows of candidates listed below
+// the command line when a completion is ambiguous.
+const maxCompletionRows = 8
+
+// CompleteFunc, if set, is called when Tab is pressed on the shell command
+// line.  It is given the command line up to the cursor, and it returns the
+// position in the line where the word being completed starts, and the
+// candidate replacements for that word.
+var CompleteFunc func(line string) (start int, candidates []string)
+
+// A completion is the state of Tab completion on the shell command line.
+type completion struct {
+	start      int      // start of the word being completed
+	candidates []string // candidates for an ambiguous completion
+	index      int      // index of the candidate currently shown, or -1
+}
+
+// complete handles a press of the Tab key.  If again is true, the previous
+// key was also Tab, and the next candidate of an ambiguous completion replaces
+// the word being completed.  Otherwise, the word is completed as far as the
+// candidates allow, and if they are ambiguous, they are listed.  It returns the
+// new line and cursor position.
+func (c *completion) complete(line string, cursor int, again bool) (string, int) {
+	if again && len(c.candidates) > 1 {
+		c.index = (c.index + 1) % len(c.candidates)
+		word := c.candidates[c.index]
+		return line[:c.start] + word + line[cursor:], c.start + len(word)
+	}
+	c.reset()
+	if CompleteFunc == nil {
+		return line, cursor
+	}
+	start, candidates := CompleteFunc(line[:cursor])
+	switch len(candidates) {
+	case 0:
+		io.WriteString(os.Stdout, "\a")
+		return line, cursor
+	case 1:
+		word := candidates[0]
+		if !strings.HasSuffix(word, "/") && !strings.HasPrefix(line[cursor:], " ") {
+			word += " "
+		}
+		return line[:start] + word + line[cursor:], start + len(word)
+	}
+	c.start, c.candidates = start, candidates
+	word := commonPrefix(candidates)
+	if len(word) < cursor-start {
+		// The candidates may differ in case from what was typed.
+		// Don't shorten the word in that case.
+		return line, cursor
+	}
+	return line[:start] + word + line[cursor:], start + len(word)
+}
+
+// reset clears the completion state.
+func (c *completion) reset() {
+	c.start, c.candidates, c.index = 0, nil, -1
+}
+
+// show lists the candidates of an ambiguous completion in the screen buffer,
+// starting at row y.
+func (c *completion) show(b *screenBuf, y int) {
+	var (
+		width int
+		cols  int
+		rows  int
+	)
+	if len(c.candidates) < 2 {
+		return
+	}
+	for _, cand := range c.candidates {
+		width = max(width, len(cand)+2)
+	}
+	cols = max(1, (len(b.lines[0].chars))/width)
+	rows = (len(c.candidates) + cols - 1) / cols
+	for i, cand := range c.candidates {
+		var color int
+
+		if i/cols >= maxCompletionRows-1 && rows > maxCompletionRows {
+			b.writeAt(0, y+maxCompletionRows-1, colorHint, "... and more")
+			break
+		}
+		if i == c.index {
+			color = colorSelected
+		}
+		b.writeAt((i%cols)*width, y+i/cols, color, setMaxLength(cand, len(b.lines[0].chars)))
+	}
+}
+
+// commonPrefix returns the longest common prefix of the strings.
+func commonPrefix(ss []string) (prefix string) {
+	prefix = ss[0]
+	for _, s := range ss[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
@@ -15,7 +15,9 @@ func Error(f string, args ...any) {
 	if !strings.HasPrefix(s, "usage: ") {
 		s = "ERROR: ⇥" + s
 	}
-	if OutputIsTerm {
+	if OutputJSON {
+		writeJSON(os.Stderr, map[string]string{"error": strings.TrimPrefix(strings.TrimSpace(s), "ERROR: ⇥")})
+	} else if OutputIsTerm {
 		clearStatus()
 		print(colorError, WrapText(s))
 		setColor(0)
@@ -0,0 +1,92 @@
+package cio
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// A HistoryItem is a single command from the shell history, for display by
+// HistoryTable.
+type HistoryItem struct {
+	Time    time.Time
+	OpCall  string
+	User    string
+	Command string
+}
+
+// SetHistory replaces the list of commands that can be recalled on the shell
+// command line (with the up and down arrows or Ctrl-R).
+func SetHistory(commands []string) {
+	history = history[:0]
+	for _, cmd := range commands {
+		if len(history) == 0 || history[len(history)-1] != cmd {
+			history = append(history, cmd)
+		}
+	}
+}
+
+// HistoryTable prints a list of commands from the shell history.
+func HistoryTable(items []*HistoryItem) {
+	if OutputJSON {
+		historyJSON(items)
+	} else if OutputIsTerm {
+		historyTable(items)
+	} else {
+		historyCSV(items)
+	}
+}
+
+func historyCSV(items []*HistoryItem) {
+	var cw *csv.Writer
+
+	if len(items) == 0 {
+		return
+	}
+	cw = csv.NewWriter(os.Stdout)
+	cw.Write([]string{"TIME", "OP CALL", "USER", "COMMAND"})
+	for _, hi := range items {
+		cw.Write([]string{hi.Time.Format(time.RFC3339), hi.OpCall, hi.User, hi.Command})
+	}
+	cw.Flush()
+}
+
+func historyJSON(items []*HistoryItem) {
+	for _, hi := range items {
+		writeJSON(os.Stdout, map[string]string{
+			"time":    jsonTime(hi.Time),
+			"opCall":  hi.OpCall,
+			"user":    hi.User,
+			"command": hi.Command,
+		})
+	}
+}
+
+func historyTable(items []*HistoryItem) {
+	var (
+		len2 = 7
+		now  = time.Now()
+	)
+	clearStatus()
+	if len(items) == 0 {
+		io.WriteString(os.Stdout, "No commands have been recorded.\n")
+		return
+	}
+	for _, hi := range items {
+		len2 = max(len2, len(hi.OpCall))
+	}
+	print(colorWhite, "TIME         "+setLength("OP CALL", len2+2)+"COMMAND")
+	print(0, "\n")
+	for _, hi := range items {
+		if now.Year() != hi.Time.Year() {
+			print(0, hi.Time.Format("2006-01-02   "))
+		} else {
+			print(0, hi.Time.Format("01/02 15:04  "))
+		}
+		print(0, setLength(hi.OpCall, len2+2))
+		print(0, setMaxLength(strings.TrimSpace(hi.Command), Width-15-len2))
+		print(0, "\n")
+	}
+}
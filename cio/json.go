@@ -0,0 +1,76 @@
+package cio
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// OutputJSON is true if output should be in JSON format rather than a table or
+// CSV.  It is set by the --format global option or the PACKET_FORMAT
+// environment variable, and it implies that OutputIsTerm is false (even with
+// --no-script).
+//
+// JSON output is written as a stream of objects, one per line.  A table
+// produces one object per row; other output produces a single object.  Errors
+// are written to standard error as objects with an "error" key.
+var OutputJSON bool
+
+// A MessageDetail is the full content of a message, for display by
+// ShowMessageJSON.
+type MessageDetail struct {
+	LMI          string         `json:"lmi"`
+	Type         string         `json:"type"`
+	TypeName     string         `json:"typeName"`
+	Status       string         `json:"status,omitempty"` // "draft", "queued", "sent", "received"
+	Bulletin     bool           `json:"bulletin,omitempty"`
+	From         string         `json:"from,omitempty"`
+	To           string         `json:"to,omitempty"`
+	Subject      string         `json:"subject,omitempty"`
+	Sent         time.Time      `json:"-"`
+	Received     time.Time      `json:"-"`
+	ReceivedBBS  string         `json:"receivedBBS,omitempty"`
+	ReceivedArea string         `json:"receivedArea,omitempty"`
+	ReadBy       []*ReadDetail  `json:"readBy,omitempty"`
+	Fields       []*FieldDetail `json:"fields"`
+}
+
+// A ReadDetail is a read receipt for a sent message.
+type ReadDetail struct {
+	Reader string `json:"reader"`
+	Time   string `json:"time"`
+}
+
+// A FieldDetail is a single field of a message.
+type FieldDetail struct {
+	Label   string `json:"label"`
+	Tag     string `json:"tag,omitempty"`
+	Value   string `json:"value"`
+	Problem string `json:"problem,omitempty"`
+}
+
+// ShowMessageJSON prints a message in JSON format.
+func ShowMessageJSON(md *MessageDetail) {
+	writeJSON(os.Stdout, struct {
+		*MessageDetail
+		Sent     string `json:"sent,omitempty"`
+		Received string `json:"received,omitempty"`
+	}{md, jsonTime(md.Sent), jsonTime(md.Received)})
+}
+
+// writeJSON writes a JSON object to w, on a single line.
+func writeJSON(w io.Writer, v any) {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.Encode(v)
+}
+
+// jsonTime returns the time in RFC 3339 format, or an empty string if the time
+// is zero.
+func jsonTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
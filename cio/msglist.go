@@ -8,7 +8,7 @@ import (
 
 type ListItem struct {
 	Handling string // "I", "P", "R", "B" for bulletin
-	Flag     string // "DRAFT", "QUEUE", "NO RCPT", "HAVE RCPT", "NEW"
+	Flag     string // "DRAFT", "QUEUE", "NO RCPT", "HAVE RCPT", "READ", "NEW"
 	Time     time.Time
 	From     string
 	LMI      string
@@ -23,7 +23,9 @@ var (
 )
 
 func ListMessage(li *ListItem) {
-	if !OutputIsTerm {
+	if OutputJSON {
+		listMessageJSON(li)
+	} else if !OutputIsTerm {
 		listMessageCSV(li)
 	} else {
 		listMessageTable(li)
@@ -44,6 +46,23 @@ func listMessageCSV(li *ListItem) {
 	listCW.Write([]string{li.Flag, tstr, li.From, li.LMI, li.To, li.Subject})
 }
 
+func listMessageJSON(li *ListItem) {
+	writeJSON(os.Stdout, listItemJSON(li))
+}
+
+// listItemJSON returns the JSON representation of a list item.
+func listItemJSON(li *ListItem) map[string]any {
+	return map[string]any{
+		"handling": li.Handling,
+		"flag":     li.Flag,
+		"time":     jsonTime(li.Time),
+		"from":     li.From,
+		"lmi":      li.LMI,
+		"to":       li.To,
+		"subject":  li.Subject,
+	}
+}
+
 func listMessageTable(li *ListItem) {
 	var lineColor int
 
@@ -82,6 +101,9 @@ func listMessageTable(li *ListItem) {
 	if li.Flag == "NO RCPT" {
 		print(colorWarningBG, li.Flag)
 		print(lineColor, "     ")
+	} else if li.Flag == "READ" {
+		print(colorSuccessBG, li.Flag)
+		print(lineColor, "        ")
 	} else if li.From != "" {
 		print(lineColor, setLength(li.From, 9)+" → ")
 	} else {
@@ -110,6 +132,10 @@ func listMessageTable(li *ListItem) {
 }
 
 func EndMessageList(s string) {
+	searchDirSeen = ""
+	if OutputJSON {
+		return
+	}
 	if listCW != nil {
 		listCW.Flush()
 		listCW = nil
@@ -10,7 +10,9 @@ import (
 var tableCW *csv.Writer
 
 func ShowNameValue(name, value string, nameWidth int) {
-	if OutputIsTerm {
+	if OutputJSON {
+		writeJSON(os.Stdout, map[string]string{"label": name, "value": value})
+	} else if OutputIsTerm {
 		showNameValueTable(name, value, nameWidth)
 	} else {
 		showNameValueCSV(name, value, nameWidth != 0)
@@ -0,0 +1,95 @@
+package cio
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"time"
+)
+
+// An OverdueItem is a sent message whose delivery receipt is overdue, for
+// display by OverdueTable.
+type OverdueItem struct {
+	LMI      string
+	Handling string // "I", "P", "R"
+	Sent     time.Time
+	To       string // recipients that haven't sent receipts
+	Subject  string
+}
+
+// OverdueTable prints the list of sent messages with overdue delivery
+// receipts.
+func OverdueTable(items []*OverdueItem) {
+	if OutputJSON {
+		overdueJSON(items)
+	} else if OutputIsTerm {
+		overdueTable(items)
+	} else {
+		overdueCSV(items)
+	}
+}
+
+func overdueCSV(items []*OverdueItem) {
+	var cw *csv.Writer
+
+	if len(items) == 0 {
+		return
+	}
+	cw = csv.NewWriter(os.Stdout)
+	cw.Write([]string{"LMI", "HANDLING", "SENT", "WAITING", "TO", "SUBJECT"})
+	for _, oi := range items {
+		cw.Write([]string{oi.LMI, oi.Handling, oi.Sent.Format(time.RFC3339), fmtDuration(time.Since(oi.Sent)), oi.To, oi.Subject})
+	}
+	cw.Flush()
+}
+
+func overdueJSON(items []*OverdueItem) {
+	for _, oi := range items {
+		writeJSON(os.Stdout, map[string]any{
+			"lmi":            oi.LMI,
+			"handling":       oi.Handling,
+			"sent":           jsonTime(oi.Sent),
+			"waitingMinutes": int(time.Since(oi.Sent) / time.Minute),
+			"to":             oi.To,
+			"subject":        oi.Subject,
+		})
+	}
+}
+
+func overdueTable(items []*OverdueItem) {
+	var (
+		lenTo = 2
+		now   = time.Now()
+	)
+	clearStatus()
+	if len(items) == 0 {
+		io.WriteString(os.Stdout, "No delivery receipts are overdue.\n")
+		return
+	}
+	for _, oi := range items {
+		lenTo = max(lenTo, len(oi.To))
+	}
+	lenTo = min(lenTo, 20)
+	print(colorWhite, "LOCAL ID   SENT         WAITING  "+setLength("TO", lenTo+2)+"SUBJECT")
+	print(0, "\n")
+	for _, oi := range items {
+		var lineColor int
+
+		switch oi.Handling {
+		case "I":
+			lineColor = colorImmediate
+		case "P":
+			lineColor = colorPriority
+		}
+		print(lineColor, setLength(oi.LMI, 11))
+		if now.Year() != oi.Sent.Year() {
+			print(lineColor, oi.Sent.Format("2006-01-02   "))
+		} else {
+			print(lineColor, oi.Sent.Format("01/02 15:04  "))
+		}
+		print(lineColor, setLength(fmtDuration(now.Sub(oi.Sent)), 9))
+		print(lineColor, setLength(oi.To, lenTo+2))
+		print(lineColor, setMaxLength(oi.Subject, Width-35-lenTo))
+		print(0, "\n")
+	}
+}
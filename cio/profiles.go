@@ -0,0 +1,93 @@
+package cio
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+
+	"github.com/rothskeller/packet-shell/config"
+)
+
+// BBSProfileTable prints the list of BBS connection profiles, in the order they
+// are tried.
+func BBSProfileTable() {
+	if OutputJSON {
+		profilesJSON()
+	} else if OutputIsTerm {
+		profilesTable()
+	} else {
+		profilesCSV()
+	}
+}
+
+func profilesCSV() {
+	var (
+		cw       *csv.Writer
+		profiles = config.C.Profiles()
+	)
+	if len(profiles) == 0 {
+		return
+	}
+	cw = csv.NewWriter(os.Stdout)
+	cw.Write([]string{"BBS", "ROLE", "CONNECTION", "ADDRESS"})
+	for i, p := range profiles {
+		cw.Write([]string{p.BBS, profileRole(i), p.ConnType(), p.BBSAddress})
+	}
+	cw.Flush()
+}
+
+func profilesJSON() {
+	for i, p := range config.C.Profiles() {
+		writeJSON(os.Stdout, map[string]string{
+			"bbs":        p.BBS,
+			"role":       profileRole(i),
+			"connection": p.ConnType(),
+			"address":    p.BBSAddress,
+		})
+	}
+}
+
+func profilesTable() {
+	var (
+		col1     = []string{"BBS"}
+		col2     = []string{"ROLE"}
+		col3     = []string{"CONNECTION"}
+		col4     = []string{"ADDRESS"}
+		len1     = 3
+		len2     = 4
+		len3     = 10
+		profiles = config.C.Profiles()
+	)
+	clearStatus()
+	if len(profiles) == 0 {
+		io.WriteString(os.Stdout, "No BBS connections are configured.\n")
+		return
+	}
+	for i, p := range profiles {
+		col1 = append(col1, p.BBS)
+		col2 = append(col2, profileRole(i))
+		col3 = append(col3, p.ConnType())
+		col4 = append(col4, p.BBSAddress)
+		len1 = max(len1, len(p.BBS))
+		len2 = max(len2, len(col2[i+1]))
+		len3 = max(len3, len(col3[i+1]))
+	}
+	for i := range col1 {
+		var color int
+		if i == 0 {
+			color = colorWhite
+		}
+		print(color, setLength(col1[i], len1+2))
+		print(color, setLength(col2[i], len2+2))
+		print(color, setLength(col3[i], len3+2))
+		print(color, col4[i])
+		print(0, "\n")
+	}
+}
+
+func profileRole(i int) string {
+	if i == 0 && config.C.BBS != "" {
+		return "primary"
+	}
+	return "backup"
+}
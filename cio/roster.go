@@ -0,0 +1,137 @@
+package cio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// A RosterItem is a single station in a net roster, for display by
+// RosterTable.
+type RosterItem struct {
+	OpCall   string
+	OpName   string
+	TacCall  string
+	TacName  string
+	CheckIn  time.Time
+	CheckOut time.Time
+	OnNet    bool
+}
+
+// RosterTable prints a net roster.
+func RosterTable(items []*RosterItem) {
+	if OutputJSON {
+		rosterJSON(items)
+	} else if OutputIsTerm {
+		rosterTable(items)
+	} else {
+		rosterCSV(items)
+	}
+}
+
+func rosterCSV(items []*RosterItem) {
+	var cw *csv.Writer
+
+	if len(items) == 0 {
+		return
+	}
+	cw = csv.NewWriter(os.Stdout)
+	cw.Write([]string{"CALL SIGN", "NAME", "TACTICAL CALL", "TACTICAL NAME", "CHECK IN", "CHECK OUT", "STATUS"})
+	for _, ri := range items {
+		var cin, cout string
+		if !ri.CheckIn.IsZero() {
+			cin = ri.CheckIn.Format(time.RFC3339)
+		}
+		if !ri.CheckOut.IsZero() {
+			cout = ri.CheckOut.Format(time.RFC3339)
+		}
+		cw.Write([]string{ri.OpCall, ri.OpName, ri.TacCall, ri.TacName, cin, cout, rosterStatus(ri)})
+	}
+	cw.Flush()
+}
+
+func rosterJSON(items []*RosterItem) {
+	for _, ri := range items {
+		writeJSON(os.Stdout, map[string]any{
+			"opCall":   ri.OpCall,
+			"opName":   ri.OpName,
+			"tacCall":  ri.TacCall,
+			"tacName":  ri.TacName,
+			"checkIn":  jsonTime(ri.CheckIn),
+			"checkOut": jsonTime(ri.CheckOut),
+			"onNet":    ri.OnNet,
+		})
+	}
+}
+
+func rosterTable(items []*RosterItem) {
+	var (
+		col1  = []string{"CALL"}
+		col2  = []string{"NAME"}
+		col3  = []string{"TAC CALL"}
+		col4  = []string{"CHECK IN"}
+		col5  = []string{"CHECK OUT"}
+		col6  = []string{"STATUS"}
+		len1  = 4
+		len2  = 4
+		len3  = 8
+		onnet int
+	)
+	clearStatus()
+	if len(items) == 0 {
+		io.WriteString(os.Stdout, "No check-in or check-out messages have been received.\n")
+		return
+	}
+	for _, ri := range items {
+		col1 = append(col1, ri.OpCall)
+		col2 = append(col2, ri.OpName)
+		col3 = append(col3, ri.TacCall)
+		col4 = append(col4, rosterTime(ri.CheckIn))
+		col5 = append(col5, rosterTime(ri.CheckOut))
+		col6 = append(col6, rosterStatus(ri))
+		len1 = max(len1, len(ri.OpCall))
+		len2 = max(len2, len(ri.OpName))
+		len3 = max(len3, len(ri.TacCall))
+		if ri.OnNet {
+			onnet++
+		}
+	}
+	len2 = min(len2, 24)
+	for i := range col1 {
+		var color int
+		if i == 0 {
+			color = colorWhite
+		}
+		print(color, setLength(col1[i], len1+2))
+		print(color, setLength(col2[i], len2+2))
+		print(color, setLength(col3[i], len3+2))
+		print(color, setLength(col4[i], 13))
+		print(color, setLength(col5[i], 13))
+		if i != 0 && items[i-1].OnNet {
+			print(colorSuccessBG, col6[i])
+		} else {
+			print(color, col6[i])
+		}
+		print(0, "\n")
+	}
+	print(0, fmt.Sprintf("%d stations, %d still on net.\n", len(items), onnet))
+}
+
+func rosterTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	if t.Year() != time.Now().Year() {
+		return t.Format("2006-01-02")
+	}
+	return t.Format("01/02 15:04")
+}
+
+func rosterStatus(ri *RosterItem) string {
+	if ri.OnNet {
+		return "on net"
+	}
+	return "checked out"
+}
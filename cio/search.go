@@ -0,0 +1,79 @@
+package cio
+
+import (
+	"encoding/csv"
+	"os"
+	"time"
+)
+
+// A SearchResult is a message that matched a search, for display by
+// ListSearchResult.
+type SearchResult struct {
+	Item    *ListItem
+	Dir     string // incident directory, if not the current one
+	Matches []*SearchMatch
+}
+
+// A SearchMatch is a single field of a message that matched a search.
+type SearchMatch struct {
+	Field   string
+	Snippet string
+}
+
+var searchDirSeen string
+
+// ListSearchResult prints a message that matched a search.  The sequence of
+// calls must be ended with a call to EndMessageList.
+func ListSearchResult(sr *SearchResult) {
+	if OutputJSON {
+		listSearchJSON(sr)
+	} else if !OutputIsTerm {
+		listSearchCSV(sr)
+	} else {
+		listSearchTable(sr)
+	}
+}
+
+func listSearchCSV(sr *SearchResult) {
+	if listCW == nil {
+		listCW = csv.NewWriter(os.Stdout)
+		listCW.Write([]string{"DIRECTORY", "FLAG", "TIME", "FROM", "LMI", "TO", "SUBJECT", "FIELD", "MATCH"})
+	}
+	var tstr string
+	if !sr.Item.Time.IsZero() {
+		tstr = sr.Item.Time.Format(time.RFC3339)
+	}
+	for _, m := range sr.Matches {
+		listCW.Write([]string{sr.Dir, sr.Item.Flag, tstr, sr.Item.From, sr.Item.LMI, sr.Item.To, sr.Item.Subject, m.Field, m.Snippet})
+	}
+}
+
+func listSearchJSON(sr *SearchResult) {
+	var (
+		obj     = listItemJSON(sr.Item)
+		matches = make([]map[string]string, 0, len(sr.Matches))
+	)
+	for _, m := range sr.Matches {
+		matches = append(matches, map[string]string{"field": m.Field, "snippet": m.Snippet})
+	}
+	obj["dir"], obj["matches"] = sr.Dir, matches
+	writeJSON(os.Stdout, obj)
+}
+
+func listSearchTable(sr *SearchResult) {
+	if sr.Dir != searchDirSeen {
+		clearStatus()
+		if listItemSeen {
+			print(0, "\n")
+		}
+		print(colorWhite, "In "+sr.Dir+":")
+		print(0, "\n")
+		searchDirSeen = sr.Dir
+	}
+	listMessageTable(sr.Item)
+	for _, m := range sr.Matches {
+		print(colorLabel, "      "+m.Field+": ")
+		print(0, setMaxLength(m.Snippet, Width-9-len(m.Field)))
+		print(0, "\n")
+	}
+}
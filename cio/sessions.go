@@ -0,0 +1,138 @@
+package cio
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// A SessionItem is the summary of a single BBS connection, for display by
+// SessionTable.
+type SessionItem struct {
+	ID            int
+	Start         time.Time
+	End           time.Time
+	BBS           string
+	Transport     string
+	Sent          int
+	Received      int
+	BytesSent     int
+	BytesReceived int
+	Error         string
+}
+
+// SessionTable prints the list of BBS connection sessions.
+func SessionTable(sessions []*SessionItem) {
+	if OutputJSON {
+		sessionsJSON(sessions)
+	} else if OutputIsTerm {
+		sessionsTable(sessions)
+	} else {
+		sessionsCSV(sessions)
+	}
+}
+
+func sessionsCSV(sessions []*SessionItem) {
+	var cw *csv.Writer
+
+	if len(sessions) == 0 {
+		return
+	}
+	cw = csv.NewWriter(os.Stdout)
+	cw.Write([]string{"SESSION", "START", "END", "BBS", "TRANSPORT", "SENT", "RECEIVED", "BYTES SENT", "BYTES RECEIVED", "ERROR"})
+	for _, s := range sessions {
+		cw.Write([]string{
+			strconv.Itoa(s.ID), s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339), s.BBS, s.Transport,
+			strconv.Itoa(s.Sent), strconv.Itoa(s.Received), strconv.Itoa(s.BytesSent), strconv.Itoa(s.BytesReceived),
+			s.Error,
+		})
+	}
+	cw.Flush()
+}
+
+func sessionsJSON(sessions []*SessionItem) {
+	for _, s := range sessions {
+		writeJSON(os.Stdout, map[string]any{
+			"session":       s.ID,
+			"start":         jsonTime(s.Start),
+			"end":           jsonTime(s.End),
+			"bbs":           s.BBS,
+			"transport":     s.Transport,
+			"sent":          s.Sent,
+			"received":      s.Received,
+			"bytesSent":     s.BytesSent,
+			"bytesReceived": s.BytesReceived,
+			"error":         s.Error,
+		})
+	}
+}
+
+func sessionsTable(sessions []*SessionItem) {
+	var (
+		col1 = []string{"#"}
+		col2 = []string{"START"}
+		col3 = []string{"TIME"}
+		col4 = []string{"BBS"}
+		col5 = []string{"VIA"}
+		col6 = []string{"SENT"}
+		col7 = []string{"RCVD"}
+		col8 = []string{"BYTES"}
+		col9 = []string{"RESULT"}
+		len1 = 1
+		len4 = 3
+		len5 = 3
+		len8 = 5
+		now  = time.Now()
+	)
+	clearStatus()
+	if len(sessions) == 0 {
+		io.WriteString(os.Stdout, "No BBS connections have been made.\n")
+		return
+	}
+	for _, s := range sessions {
+		col1 = append(col1, strconv.Itoa(s.ID))
+		if now.Year() != s.Start.Year() {
+			col2 = append(col2, s.Start.Format("2006-01-02"))
+		} else {
+			col2 = append(col2, s.Start.Format("01/02 15:04"))
+		}
+		col3 = append(col3, s.End.Sub(s.Start).Round(time.Second).String())
+		col4 = append(col4, s.BBS)
+		col5 = append(col5, s.Transport)
+		col6 = append(col6, strconv.Itoa(s.Sent))
+		col7 = append(col7, strconv.Itoa(s.Received))
+		col8 = append(col8, fmt.Sprintf("%d/%d", s.BytesSent, s.BytesReceived))
+		if s.Error != "" {
+			col9 = append(col9, s.Error)
+		} else {
+			col9 = append(col9, "OK")
+		}
+		len1 = max(len1, len(col1[len(col1)-1]))
+		len4 = max(len4, len(s.BBS))
+		len5 = max(len5, len(s.Transport))
+		len8 = max(len8, len(col8[len(col8)-1]))
+	}
+	for i := range col1 {
+		var color int
+		if i == 0 {
+			color = colorWhite
+		}
+		print(color, setLength(col1[i], len1+2))
+		print(color, setLength(col2[i], 13))
+		print(color, setLength(col3[i], 9))
+		print(color, setLength(col4[i], len4+2))
+		print(color, setLength(col5[i], len5+2))
+		print(color, setLength(col6[i], 6))
+		print(color, setLength(col7[i], 6))
+		print(color, setLength(col8[i], len8+2))
+		if i != 0 && col9[i] != "OK" {
+			print(colorAlertBG, col9[i])
+		} else {
+			print(color, col9[i])
+		}
+		print(0, "\n")
+	}
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"github.com/rothskeller/packet-shell/cio"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	ackSlug = `Acknowledge received immediate messages`
+	ackHelp = `
+usage: packet ack [«message-id»...]
+
+When an immediate message is received in the packet shell (by the "connect" or "watch" command), the shell rings the terminal bell and displays a red banner.  Until the message is acknowledged, the banner is repeated above every shell prompt, the prompt includes a count of unacknowledged immediate messages, and the bell rings every 15 seconds while the shell waits for a command.
+
+The "ack" command acknowledges the named immediate messages, or all of them if no «message-id» is given.  Each «message-id» can be just the numeric part of the ID if that is unique.  Viewing a message with the "show", "pdf", or "dump" command also acknowledges it.
+`
+)
+
+func cmdAck(args []string) (err error) {
+	var lmis []string
+
+	flags := pflag.NewFlagSet("ack", pflag.ContinueOnError)
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"ack"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(ackHelp)
+	}
+	for _, arg := range flags.Args() {
+		lmi, err := expandMessageID(arg, true)
+		if err != nil {
+			return err
+		}
+		lmis = append(lmis, lmi)
+	}
+	switch count := cio.AcknowledgeAlerts(lmis...); count {
+	case 0:
+		cio.Confirm("No unacknowledged immediate messages.")
+	case 1:
+		cio.Confirm("Acknowledged 1 immediate message.")
+	default:
+		cio.Confirm("Acknowledged %d immediate messages.", count)
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet-shell/config"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	aliasSlug = `Define, list, or remove command aliases`
+	aliasHelp = `
+usage: packet alias [--global] ⇥[«name»[=«expansion»]]
+       packet alias [--global] ⇥«name» «expansion»...
+       packet unalias [--global] «name»
+  -g, --global  ⇥define or remove an alias for all incidents
+
+The "alias" command defines a user-defined command alias.  When «name» is used as a command, «expansion» is run in its place.  For example, after ` + "`" + `alias sitrep='new sr'` + "`" + `, the command "sitrep" creates a new situation report.  Any arguments given to the alias are appended to the expansion, unless the expansion refers to them explicitly as $1, $2, etc. (or $* for all of them).
+
+An expansion can contain several commands separated by semicolons, making a macro.  The commands are run in order, stopping at the first one that fails.  Variables (see "packet help source") are expanded as each command is run, so $LMI refers to the message created by an earlier "new" command in the macro.  For example:
+    ⇥alias checkin='new ci; queue $LMI; connect'
+The single quotes keep the $LMI reference from being replaced when the alias is defined in a command file.  (Within double quotes, or without quotes, write $$LMI instead.)  A semicolon within quotes in the expansion is part of a command rather than a separator.
+
+By default, an alias is defined only for the current incident directory, and is saved in its packet.conf file.  With the --global (-g) flag, the alias is defined for all incidents, and is saved in the .packet file in the user's home directory.  An incident alias takes precedence over a global alias with the same name.  Aliases can also be added to those files by hand, in their "Aliases" setting.
+
+An alias cannot have the same name as a built-in command or abbreviation; such aliases are ignored if found in the configuration files.  Aliases can refer to other aliases, but not to themselves.
+
+With only a «name», the "alias" command shows the expansion of that alias.  With no arguments, it lists all aliases.  They are also listed by "packet help".  The "unalias" command removes an alias:  the incident alias with that name if there is one, and otherwise the global one.  With the --global (-g) flag, it removes the global alias.
+`
+)
+
+// maxAliasDepth is the maximum nesting of alias expansions.
+const maxAliasDepth = 10
+
+var (
+	aliasNameRE = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_-]*$`)
+	aliasArgRE  = regexp.MustCompile(`\$[1-9*]`)
+	// expanding is the list of aliases currently being expanded.
+	expanding []string
+)
+
+func cmdAlias(cmdname string, args []string) (err error) {
+	var global bool
+
+	flags := pflag.NewFlagSet(cmdname, pflag.ContinueOnError)
+	flags.BoolVarP(&global, "global", "g", false, "define or remove an alias for all incidents")
+	flags.Usage = func() {} // we do our own
+	flags.SetInterspersed(false)
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{cmdname})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(aliasHelp)
+	}
+	args = flags.Args()
+	if cmdname == "unalias" {
+		if len(args) != 1 {
+			return usage(aliasHelp)
+		}
+		// Without --global, remove the alias in effect:  the incident
+		// one if there is one, otherwise the global one.
+		switch local, home := config.C.HasAlias(args[0]); {
+		case !local && !home:
+			return fmt.Errorf("no such alias %q", args[0])
+		case global && !home:
+			return fmt.Errorf("%q is an incident alias, not a global one", args[0])
+		case global || !local:
+			config.C.SetAlias(args[0], "", true)
+		default:
+			config.C.SetAlias(args[0], "", false)
+		}
+		config.SaveConfig()
+		return nil
+	}
+	if len(args) == 0 {
+		for _, name := range config.C.AliasNames() {
+			showAlias(name)
+		}
+		cio.EndNameValueList()
+		return nil
+	}
+	name, expansion, found := strings.Cut(args[0], "=")
+	if !found && len(args) == 1 {
+		if _, ok := config.C.Alias(name); !ok {
+			return fmt.Errorf("no such alias %q", name)
+		}
+		showAlias(name)
+		cio.EndNameValueList()
+		return nil
+	}
+	if found && len(args) > 1 {
+		return usage(aliasHelp)
+	}
+	if !found {
+		expansion = strings.Join(args[1:], " ")
+	}
+	if !aliasNameRE.MatchString(name) {
+		return fmt.Errorf("%q is not a valid alias name", name)
+	}
+	if isBuiltinCommand(name) {
+		return fmt.Errorf("%q is a built-in command and cannot be redefined", name)
+	}
+	if expansion = strings.TrimSpace(expansion); expansion == "" {
+		return errors.New("missing alias expansion")
+	}
+	config.C.SetAlias(name, expansion, global)
+	config.SaveConfig()
+	return nil
+}
+
+// showAlias displays the named alias and its expansion.
+func showAlias(name string) {
+	var (
+		expansion, _ = config.C.Alias(name)
+		label        = name
+	)
+	if config.C.IsGlobalAlias(name) {
+		label += " (global)"
+	}
+	if isBuiltinCommand(name) {
+		expansion += "  [ignored: same name as built-in command]"
+	}
+	cio.ShowNameValue(label, expansion, 20)
+}
+
+// isBuiltinCommand returns whether the name is a built-in command or
+// abbreviation.
+func isBuiltinCommand(name string) bool {
+	return findBuiltin(name) != nil
+}
+
+// runAlias runs the expansion of an alias.  args[0] is the alias name and the
+// rest are its arguments.
+func runAlias(args []string, expansion string) (err error) {
+	if slices.Contains(expanding, args[0]) {
+		return fmt.Errorf("alias %q refers to itself", args[0])
+	}
+	if len(expanding) >= maxAliasDepth {
+		return errors.New("aliases nested too deeply")
+	}
+	expanding = append(expanding, args[0])
+	defer func() { expanding = expanding[:len(expanding)-1] }()
+	for _, line := range substituteAliasArgs(expansion, args[1:]) {
+		var (
+			cmdargs []string
+			in, out *os.File
+		)
+		if line, err = expandVariables(line); err != nil {
+			return fmt.Errorf("%s: %s", args[0], err)
+		}
+		if cmdargs, in, out, err = parseCommandLine(line); err != nil {
+			return fmt.Errorf("%s: %s", args[0], err)
+		}
+		if len(cmdargs) != 0 && cmdargs[0] == "packet" {
+			cmdargs = cmdargs[1:]
+		}
+		if len(cmdargs) == 0 {
+			continue
+		}
+		if err = runRedirected(cmdargs, in, out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// substituteAliasArgs splits the expansion into its separate commands (at
+// semicolons that aren't quoted) and substitutes the alias arguments into them.  If the expansion doesn't refer to
+// the arguments, they are appended to the last command.  Arguments containing
+// spaces or special characters are quoted.
+func substituteAliasArgs(expansion string, args []string) (lines []string) {
+	var quoted = make([]string, len(args))
+
+	for i, arg := range args {
+		if strings.ContainsAny(arg, " \t<>;") || arg == "" {
+			if strings.Contains(arg, "'") {
+				quoted[i] = `"` + arg + `"`
+			} else {
+				quoted[i] = "'" + arg + "'"
+			}
+		} else {
+			quoted[i] = arg
+		}
+	}
+	lines = splitCommands(expansion)
+	if !aliasArgRE.MatchString(expansion) {
+		if len(quoted) != 0 {
+			lines[len(lines)-1] += " " + strings.Join(quoted, " ")
+		}
+		return lines
+	}
+	for i, line := range lines {
+		lines[i] = aliasArgRE.ReplaceAllStringFunc(line, func(ref string) string {
+			if ref == "$*" {
+				return strings.Join(quoted, " ")
+			}
+			if n, _ := strconv.Atoi(ref[1:]); n <= len(quoted) {
+				return quoted[n-1]
+			}
+			return ""
+		})
+	}
+	return lines
+}
+
+// aliasHelpText returns the list of aliases for the top-level help text, or an
+// empty string if there are none.
+func aliasHelpText() string {
+	var sb strings.Builder
+
+	for _, name := range config.C.AliasNames() {
+		if isBuiltinCommand(name) {
+			continue
+		}
+		expansion, _ := config.C.Alias(name)
+		fmt.Fprintf(&sb, "  %s ⇥%s\n", setWidth(name, 10), expansion)
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "User-defined aliases (see \"packet help alias\"):\n" + sb.String()
+}
+
+// setWidth pads a string with spaces to at least the specified width.
+func setWidth(s string, w int) string {
+	if len(s) < w {
+		return s + strings.Repeat(" ", w-len(s))
+	}
+	return s
+}
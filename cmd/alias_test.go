@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSubstituteAliasArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		expansion string
+		args      []string
+		want      []string
+	}{
+		{"appended", "new sr", []string{"AAA-101P"}, []string{"new sr AAA-101P"}},
+		{"macro", "new ci; queue $LMI; connect", nil, []string{"new ci", " queue $LMI", " connect"}},
+		{"appended to last", "new ci; show", []string{"x"}, []string{"new ci", " show x"}},
+		{"numbered", "set $1 subject $2", []string{"AAA-101P", "hi there"}, []string{"set AAA-101P subject 'hi there'"}},
+		{"all", "list $*; show", []string{"a", "b"}, []string{"list a b", " show"}},
+		{"quoted argument with semicolon", "set $1 body $2", []string{"AAA-101P", "a;b"}, []string{"set AAA-101P body 'a;b'"}},
+		{"single quoted semicolon", "set AAA-101P body 'one; two'; show AAA-101P", nil, []string{"set AAA-101P body 'one; two'", " show AAA-101P"}},
+		{"double quoted semicolon", `set AAA-101P body "it's; done"; show`, nil, []string{`set AAA-101P body "it's; done"`, " show"}},
+		{"unterminated quote", "set AAA-101P body 'a; b", nil, []string{"set AAA-101P body 'a; b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := substituteAliasArgs(tt.expansion, tt.args); !slices.Equal(got, tt.want) {
+				t.Errorf("substituteAliasArgs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet-shell/config"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	bbsSlug = `Manage backup BBS connections`
+	bbsHelp = `
+usage: packet bbs ⇥[flags] [«bbs»]
+  -b, --backup  ⇥save current connection settings as a backup
+  -d, --delete  ⇥delete the backup for «bbs»
+  -u, --use     ⇥make the backup for «bbs» the primary connection
+These flags are mutually exclusive.
+
+The "bbs" command manages the list of BBS connections.  The primary connection is the one in the configuration settings (see "packet help config").  In addition, there can be any number of backup connections, one per BBS.  The "connect" command tries the primary connection first, and then each backup connection in turn if the previous ones could not be reached.  (The --bbs flag of the "connect" command can be used to select a single connection instead.)
+
+With the --backup (-b) flag, the current BBS connection settings are added to the end of the list of backup connections, replacing any backup for the same BBS.  The configuration settings can then be changed to those for a different BBS.  No «bbs» may be given with this flag.
+
+With the --delete (-d) flag, the backup connection for the named «bbs» is deleted.
+
+With the --use (-u) flag, the backup connection for the named «bbs» becomes the primary connection (i.e., its settings are copied into the configuration settings), and the former primary connection takes its place in the list of backups.
+
+In all cases, the command prints the resulting list of BBS connections, in the order they are tried.  The table will be in human format if stdout is a terminal, and in CSV format otherwise.
+`
+)
+
+func cmdBBS(args []string) (err error) {
+	var (
+		backup bool
+		del    bool
+		use    bool
+		flags  = pflag.NewFlagSet("bbs", pflag.ContinueOnError)
+	)
+	flags.BoolVarP(&backup, "backup", "b", false, "save current connection settings as a backup")
+	flags.BoolVarP(&del, "delete", "d", false, "delete the backup for «bbs»")
+	flags.BoolVarP(&use, "use", "u", false, "make the backup for «bbs» the primary connection")
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"bbs"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(bbsHelp)
+	} else if err = gaveMutuallyExclusiveFlags(flags, "backup", "delete", "use"); err != nil {
+		cio.Error("%s", err.Error())
+		return usage(bbsHelp)
+	}
+	args = flags.Args()
+	switch {
+	case backup && len(args) != 0, (del || use) && len(args) != 1, !backup && !del && !use && len(args) != 0:
+		return usage(bbsHelp)
+	case backup:
+		err = doBBSBackup()
+	case del:
+		err = doBBSDelete(args[0])
+	case use:
+		err = doBBSUse(args[0])
+	}
+	if err != nil {
+		return err
+	}
+	cio.BBSProfileTable()
+	return nil
+}
+
+// doBBSBackup adds the current connection settings to the list of backups.
+func doBBSBackup() error {
+	var primary = config.C.Primary()
+
+	if primary.BBS == "" || !haveProfileConfig(primary) {
+		return errors.New("the current BBS connection settings are incomplete")
+	}
+	config.C.BackupBBSes = slices.DeleteFunc(config.C.BackupBBSes, func(p *config.BBSProfile) bool {
+		return strings.EqualFold(p.BBS, primary.BBS)
+	})
+	config.C.BackupBBSes = append(config.C.BackupBBSes, primary)
+	config.SaveConfig()
+	return nil
+}
+
+// doBBSDelete deletes the backup connection for the named BBS.
+func doBBSDelete(bbs string) error {
+	var idx = backupIndex(bbs)
+
+	if idx < 0 {
+		return fmt.Errorf("no backup connection for %q", bbs)
+	}
+	config.C.BackupBBSes = slices.Delete(config.C.BackupBBSes, idx, idx+1)
+	config.SaveConfig()
+	return nil
+}
+
+// doBBSUse swaps the backup connection for the named BBS with the primary
+// connection.
+func doBBSUse(bbs string) error {
+	var idx = backupIndex(bbs)
+
+	if idx < 0 {
+		return fmt.Errorf("no backup connection for %q", bbs)
+	}
+	use := config.C.BackupBBSes[idx]
+	if config.C.BBS != "" {
+		config.C.BackupBBSes[idx] = config.C.Primary()
+	} else {
+		config.C.BackupBBSes = slices.Delete(config.C.BackupBBSes, idx, idx+1)
+	}
+	config.C.SetPrimary(use)
+	config.SaveConfig()
+	return nil
+}
+
+// backupIndex returns the index of the backup connection for the named BBS,
+// or -1 if there is none.
+func backupIndex(bbs string) int {
+	return slices.IndexFunc(config.C.BackupBBSes, func(p *config.BBSProfile) bool {
+		return strings.EqualFold(p.BBS, bbs)
+	})
+}
@@ -7,10 +7,13 @@ import (
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 
 	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet-shell/config"
 	"github.com/rothskeller/packet/incident"
 	"github.com/rothskeller/packet/message"
 	"github.com/spf13/pflag"
@@ -23,18 +26,30 @@ func Run(args []string) (ok bool) {
 
 	defer func() {
 		if p := recover(); p != nil {
-			if logfile, err := os.OpenFile("packet.log", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666); err == nil {
+			if logfile, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666); err == nil {
 				fmt.Fprintf(logfile, "PANIC: %v\n%s\n", p, debug.Stack())
 				logfile.Close()
 			}
 			panic(p)
 		}
 	}()
+	args, err = globalOptions(args)
+	if err == nil && len(args) != 0 && args[0] == "-f" {
+		// "packet -f «file»" is the same as "packet source «file»".
+		args[0] = "source"
+	}
 	cio.Detect()
+	if err != nil {
+		cio.Error("%s", err.Error())
+		return false
+	}
 	safeDir = safeDirectory()
 	if len(args) == 0 {
 		err = shell()
-	} else if safeDir != "" {
+	} else if args[0] == "__complete" {
+		// Shell completion must work (quietly) everywhere.
+		err = cmdComplete(args[1:])
+	} else if safeDir != "" && args[0] != "completion" {
 		err = errors.New(safeDir)
 	} else {
 		err = run(args)
@@ -46,47 +61,140 @@ func Run(args []string) (ok bool) {
 	return true
 }
 
-func run(args []string) (err error) {
-	switch args[0] {
-	case "b", "bull", "bulletin", "bulletins":
-		return cmdBulletins(args[1:])
-	case "cd", "chdir", "md", "mkdir", "pwd":
-		return cmdChdir(args[0], args[1:])
-	case "c", "connect":
-		return cmdConnect(args[1:])
-	case "delete":
-		return cmdDelete(args[1:])
-	case "draft":
-		return cmdDraft(args[1:])
-	case "dump":
-		return cmdDump(args[1:])
-	case "e", "edit":
-		return cmdEdit(args[1:])
-	case "h", "help", "--help", "-?":
-		return cmdHelp(args[1:])
-	case "309", "ics309":
-		return cmdICS309(args[1:])
-	case "l", "list":
-		return cmdList(args[1:])
-	case "n", "new":
-		return cmdNew(args[1:])
-	case "pdf":
-		return cmdPDF(args[1:])
-	case "queue":
-		return cmdQueue(args[1:])
-	case "q", "quit", "exit":
-		return ErrQuit
-	case "set":
-		return cmdSet(args[1:])
-	case "s", "show":
-		return cmdShow(args[1:])
-	case "version":
-		return cmdVersion(args[1:])
-	case "config", "script", "types":
-		return cmdHelp(args[1:])
+// globalOptions applies the global options at the start of the command line,
+// and their environment variable equivalents.  It returns the remainder of the
+// command line.
+func globalOptions(args []string) (_ []string, err error) {
+	var format = os.Getenv("PACKET_FORMAT")
+
+	cio.ScriptMode = cio.ScriptAuto
+	if env := os.Getenv("PACKET_SCRIPT"); env != "" {
+		if script, err := strconv.ParseBool(env); err != nil {
+			return nil, fmt.Errorf("invalid PACKET_SCRIPT value %q (must be \"true\" or \"false\")", env)
+		} else if script {
+			cio.ScriptMode = cio.ScriptOn
+		} else {
+			cio.ScriptMode = cio.ScriptOff
+		}
+	}
+	for len(args) != 0 {
+		if args[0] == "--script" {
+			cio.ScriptMode, args = cio.ScriptOn, args[1:]
+		} else if args[0] == "--no-script" {
+			cio.ScriptMode, args = cio.ScriptOff, args[1:]
+		} else if args[0] == "--format" {
+			if len(args) == 1 {
+				return nil, errors.New("--format requires an argument")
+			}
+			format, args = args[1], args[2:]
+		} else if f, ok := strings.CutPrefix(args[0], "--format="); ok {
+			format, args = f, args[1:]
+		} else {
+			break
+		}
+	}
+	switch strings.ToLower(format) {
+	case "", "text":
+		cio.OutputJSON = false
+	case "json":
+		cio.OutputJSON = true
 	default:
-		return fmt.Errorf("no such command %q", args[0])
+		return nil, fmt.Errorf("unknown output format %q (must be \"text\" or \"json\")", format)
+	}
+	return args, nil
+}
+
+// A builtin is a built-in command.  Its handler is called with the name by
+// which the command was invoked and the rest of the command line.
+type builtin struct {
+	names   []string
+	handler func(name string, args []string) error
+	hidden  bool // not offered for completion
+}
+
+// builtins is the table of built-in commands.  It is used by run to dispatch
+// commands, and by completion and alias definition for the list of command
+// names.  It is filled in by init, since some of the handlers call run.
+var builtins []*builtin
+
+func init() {
+	builtins = []*builtin{
+		{names: []string{"ack"}, handler: argsOnly(cmdAck)},
+		{names: []string{"alias", "unalias"}, handler: cmdAlias},
+		{names: []string{"b", "bull", "bulletin", "bulletins"}, handler: argsOnly(cmdBulletins)},
+		{names: []string{"bbs"}, handler: argsOnly(cmdBBS)},
+		{names: []string{"cd", "chdir", "md", "mkdir", "pwd"}, handler: cmdChdir},
+		{names: []string{"completion"}, handler: argsOnly(cmdCompletion)},
+		{names: []string{"c", "connect"}, handler: argsOnly(cmdConnect)},
+		{names: []string{"delete"}, handler: argsOnly(cmdDelete)},
+		{names: []string{"draft"}, handler: argsOnly(cmdDraft)},
+		{names: []string{"dump"}, handler: argsOnly(cmdDump)},
+		{names: []string{"e", "edit"}, handler: argsOnly(cmdEdit)},
+		{names: []string{"h", "help"}, handler: argsOnly(cmdHelp)},
+		{names: []string{"--help", "-?"}, handler: argsOnly(cmdHelp), hidden: true},
+		{names: []string{"history"}, handler: argsOnly(cmdHistory)},
+		{names: []string{"309", "ics309"}, handler: argsOnly(cmdICS309)},
+		{names: []string{"l", "list"}, handler: argsOnly(cmdList)},
+		{names: []string{"log"}, handler: argsOnly(cmdLog)},
+		{names: []string{"n", "new"}, handler: argsOnly(cmdNew)},
+		{names: []string{"overdue"}, handler: argsOnly(cmdOverdue)},
+		{names: []string{"pdf"}, handler: argsOnly(cmdPDF)},
+		{names: []string{"queue"}, handler: argsOnly(cmdQueue)},
+		{names: []string{"q", "quit", "exit"}, handler: func(string, []string) error { return ErrQuit }},
+		{names: []string{"resend"}, handler: argsOnly(cmdResend)},
+		{names: []string{"roster"}, handler: argsOnly(cmdRoster)},
+		{names: []string{"search"}, handler: argsOnly(cmdSearch)},
+		{names: []string{"set"}, handler: argsOnly(cmdSet)},
+		{names: []string{"s", "show"}, handler: argsOnly(cmdShow)},
+		{names: []string{"source"}, handler: argsOnly(cmdSource)},
+		{names: []string{"."}, handler: argsOnly(cmdSource), hidden: true},
+		{names: []string{"simbbs"}, handler: argsOnly(cmdSimBBS)},
+		{names: []string{"version"}, handler: argsOnly(cmdVersion)},
+		{names: []string{"watch"}, handler: argsOnly(cmdWatch)},
+		{names: []string{"config", "script", "types"}, handler: argsOnly(cmdHelp), hidden: true},
+		// "__complete" is handled by Run, but is listed here so that
+		// it can't be redefined as an alias.
+		{names: []string{"__complete"}, handler: argsOnly(cmdComplete), hidden: true},
+	}
+}
+
+// argsOnly adapts a command function that doesn't care about the name by which
+// it was invoked.
+func argsOnly(fn func(args []string) error) func(string, []string) error {
+	return func(_ string, args []string) error { return fn(args) }
+}
+
+// findBuiltin returns the built-in command with the specified name, or nil if
+// there is none.
+func findBuiltin(name string) *builtin {
+	for _, b := range builtins {
+		if slices.Contains(b.names, name) {
+			return b
+		}
+	}
+	return nil
+}
+
+// commandNames returns the sorted list of built-in command names (including
+// abbreviations) that are offered for completion.
+func commandNames() (names []string) {
+	for _, b := range builtins {
+		if !b.hidden {
+			names = append(names, b.names...)
+		}
 	}
+	sort.Strings(names)
+	return names
+}
+
+func run(args []string) (err error) {
+	if b := findBuiltin(args[0]); b != nil {
+		return b.handler(args[0], args[1:])
+	}
+	if expansion, ok := config.C.Alias(args[0]); ok {
+		return runAlias(args, expansion)
+	}
+	return fmt.Errorf("no such command %q", args[0])
 }
 
 func shell() (err error) {
@@ -101,15 +209,29 @@ func shell() (err error) {
 	if safeDir != "" {
 		cio.Confirm(`WARNING: %s  Use the "cd" command to switch to a different directory.`, safeDir)
 	}
+	cio.CompleteFunc = completeCommandLine
+	var (
+		historyDir  string // directory whose history is loaded
+		historySafe bool   // whether to record history in it
+	)
 	for {
 		var (
-			line    string
-			args    []string
-			in      *os.File
-			out     *os.File
-			saveIn  *os.File
-			saveOut *os.File
+			line string
+			args []string
+			in   *os.File
+			out  *os.File
 		)
+		// If we're in a different directory than before, switch to its
+		// command history.  We don't keep history in unsafe
+		// directories.
+		if wd, _ := os.Getwd(); wd != historyDir {
+			historyDir, historySafe = wd, safeDirectory() == ""
+			if historySafe {
+				loadHistory()
+			} else {
+				cio.SetHistory(nil)
+			}
+		}
 		// If the directory is not safe, give a warning to them to
 		// change it.  Then clear the problem so we don't trip over it
 		// again.
@@ -137,26 +259,11 @@ func shell() (err error) {
 		if len(args) == 0 {
 			continue
 		}
-		// Save the old stdin and stdout and apply the new ones.
-		saveIn, saveOut = os.Stdin, os.Stdout
-		if in != nil {
-			os.Stdin = in
-		}
-		if out != nil {
-			os.Stdout = out
+		if historySafe {
+			recordHistory(line, args)
 		}
-		cio.Detect()
 		// Run the command.
-		err = run(args)
-		// Restore the old stdin and stdout.
-		os.Stdin, os.Stdout = saveIn, saveOut
-		if in != nil {
-			in.Close()
-		}
-		if out != nil {
-			out.Close()
-		}
-		cio.Detect()
+		err = runRedirected(args, in, out)
 		// Handle the result of the command.
 		if err != nil && err != ErrQuit {
 			cio.Error("%s", err.Error())
@@ -167,6 +274,38 @@ func shell() (err error) {
 	}
 }
 
+// runRedirected runs a command with its standard input and output redirected
+// to the specified files (if not nil).  It closes those files when the command
+// finishes.
+func runRedirected(args []string, in, out *os.File) (err error) {
+	// Save the old stdin and stdout and apply the new ones.
+	saveIn, saveOut, saveMode := os.Stdin, os.Stdout, cio.ScriptMode
+	if in != nil {
+		os.Stdin = in
+	}
+	if out != nil {
+		os.Stdout = out
+	}
+	// Forcing interactive behavior makes no sense for a redirected file,
+	// so --no-script gives way to detection for this command.
+	if (in != nil || out != nil) && cio.ScriptMode == cio.ScriptOff {
+		cio.ScriptMode = cio.ScriptAuto
+	}
+	cio.Detect()
+	// Run the command.
+	err = run(args)
+	// Restore the old stdin and stdout.
+	os.Stdin, os.Stdout, cio.ScriptMode = saveIn, saveOut, saveMode
+	if in != nil {
+		in.Close()
+	}
+	if out != nil {
+		out.Close()
+	}
+	cio.Detect()
+	return err
+}
+
 // expandMessageID searches all messages in the current directory for those
 // whose local message ID matches the supplied input.  If it finds exactly one,
 // it returns the full message ID.  If it finds more than one, it returns an
@@ -420,6 +559,31 @@ func tokenizeLine(line string) (args []string) {
 	return args
 }
 
+// splitCommands splits a line containing several commands, separated by
+// semicolons, into the separate commands.  Semicolons within quotes are not
+// separators; quoting follows the same rules as in tokenizeLine.  The quotes
+// themselves are left in the commands, to be removed when each command is
+// parsed.
+func splitCommands(line string) (commands []string) {
+	var start int
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\'', '"':
+			// Skip to the closing quote.  An unterminated quote runs
+			// to the end of the line.
+			if end := strings.IndexByte(line[i+1:], line[i]); end >= 0 {
+				i += end + 1
+			} else {
+				i = len(line)
+			}
+		case ';':
+			commands, start = append(commands, line[start:i]), i+1
+		}
+	}
+	return append(commands, line[start:])
+}
+
 func safeDirectory() string {
 	var (
 		cwd  string
@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/rothskeller/packet-shell/config"
+	"github.com/rothskeller/packet/incident"
+	"github.com/rothskeller/packet/message"
+
+	"golang.org/x/exp/maps"
+)
+
+// helpTopics is the list of help topics offered for completion after "help".
+var helpTopics = []string{"config", "files", "hooks", "script", "types"}
+
+// completeCommandLine returns the candidate completions for the last word of
+// the (partial) shell command line.  It is called by cio when Tab is pressed.
+func completeCommandLine(line string) (start int, candidates []string) {
+	var (
+		word  string
+		words []string
+		args  []string
+	)
+	start = strings.LastIndexAny(line, " \t<>") + 1
+	if word = line[start:]; strings.ContainsAny(word, `'"`) {
+		return start, nil // we don't complete quoted words
+	}
+	before := strings.TrimRight(line[:start], " \t")
+	if strings.HasSuffix(before, "<") || strings.HasSuffix(before, ">") {
+		return start, completeFiles(word, false)
+	}
+	words = tokenizeLine(before)
+	// Remove redirections; they don't affect completion of other words.
+	for i := 0; i < len(words); i++ {
+		if words[i] == "<" || words[i] == ">" || words[i] == ">>" {
+			words = append(words[:i], words[min(i+2, len(words)):]...)
+			i--
+		}
+	}
+	if len(words) != 0 && words[0] == "packet" {
+		words = words[1:]
+	}
+	if len(words) == 0 {
+		return start, matchPrefix(word, append(commandNames(), config.C.AliasNames()...))
+	}
+	if strings.HasPrefix(word, "-") {
+		return start, nil
+	}
+	for _, w := range words[1:] {
+		if !strings.HasPrefix(w, "-") {
+			args = append(args, w)
+		}
+	}
+	prev := words[len(words)-1]
+	switch words[0] {
+	case "h", "help":
+		if len(args) == 0 {
+			return start, matchPrefix(word, append(commandNames(), helpTopics...))
+		}
+	case "s", "show":
+		switch len(args) {
+		case 0:
+			return start, matchPrefix(word, append(completeMessageIDs(), "config"))
+		case 1:
+			return start, matchPrefix(word, completeFieldNames(args[0], false))
+		}
+	case "e", "edit", "set":
+		switch len(args) {
+		case 0:
+			return start, matchPrefix(word, append(completeMessageIDs(), "config"))
+		case 1:
+			return start, matchPrefix(word, completeFieldNames(args[0], true))
+		}
+	case "ack", "delete", "draft", "dump", "pdf", "queue":
+		return start, matchPrefix(word, completeMessageIDs())
+	case "resend":
+		if len(args) == 0 {
+			return start, matchPrefix(word, completeMessageIDs())
+		}
+	case "n", "new":
+		if prev == "-r" || prev == "--reply" || prev == "-c" || prev == "--copy" {
+			return start, matchPrefix(word, completeMessageIDs())
+		}
+		if slices.Contains(words, "-c") || slices.Contains(words, "--copy") {
+			return start, nil
+		}
+		if len(args) == 0 || (len(args) == 1 && (slices.Contains(words, "-r") || slices.Contains(words, "--reply"))) {
+			return start, matchPrefix(word, completeMessageTypes())
+		}
+	case "b", "bull", "bulletin", "bulletins":
+		return start, matchPrefix(word, maps.Keys(config.C.Bulletins))
+	case "source", ".":
+		if len(args) == 0 {
+			return start, completeFiles(word, false)
+		}
+	case "alias", "unalias":
+		if len(args) == 0 {
+			return start, matchPrefix(word, config.C.AliasNames())
+		}
+	case "completion":
+		if len(args) == 0 {
+			return start, matchPrefix(word, []string{"bash", "fish", "zsh"})
+		}
+	case "cd", "chdir", "md", "mkdir":
+		if len(args) == 0 {
+			return start, completeFiles(word, true)
+		}
+	}
+	return start, nil
+}
+
+// completeMessageIDs returns the local and remote message IDs of all messages
+// in the current incident.
+func completeMessageIDs() (ids []string) {
+	lmis, _ := incident.AllLMIs()
+	ids = append(ids, lmis...)
+	for _, lmi := range lmis {
+		env, _, err := incident.ReadMessage(lmi)
+		if err != nil {
+			continue
+		}
+		if env.IsReceived() {
+			if rmi, _, _, _, _ := message.DecodeSubject(env.SubjectLine); rmi != "" && incident.LMIForRMI(rmi) != "" {
+				ids = append(ids, rmi)
+			}
+		} else if delivs, err := incident.Deliveries(lmi); err == nil {
+			for _, deliv := range delivs {
+				if deliv.RemoteMessageID != "" && incident.LMIForRMI(deliv.RemoteMessageID) != "" {
+					ids = append(ids, deliv.RemoteMessageID)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// completeFieldNames returns the names of the fields of the specified message,
+// with the spaces removed (as accepted by expandFieldName).  If editable is
+// true, only editable fields are returned.
+func completeFieldNames(id string, editable bool) (names []string) {
+	var msg message.Message
+
+	if strings.HasPrefix("config", id) {
+		msg = &config.C
+	} else if lmi, err := expandMessageID(id, true); err != nil {
+		return nil
+	} else if _, msg, err = incident.ReadMessage(lmi); err != nil {
+		return nil
+	} else if editable {
+		names = append(names, "To")
+	}
+	for _, f := range msg.Base().Fields {
+		if f.Label == "" || (editable && f.EditHelp == "") {
+			continue
+		}
+		names = append(names, strings.ReplaceAll(f.Label, " ", ""))
+	}
+	return names
+}
+
+// completeMessageTypes returns the tags and aliases of the message types that
+// can be created with "new".
+func completeMessageTypes() (tags []string) {
+	for tag := range message.RegisteredTypes {
+		if msg := message.Create(tag, ""); msg != nil && msg.Editable() {
+			tags = append(tags, tag)
+		}
+	}
+	return append(tags, maps.Keys(aliases)...)
+}
+
+// completeFiles returns the names of the files (or, if dirsOnly is true, the
+// directories) that start with the supplied partial path.  Directory names
+// have a trailing slash.
+func completeFiles(partial string, dirsOnly bool) (names []string) {
+	var dir, base = filepath.Split(partial)
+
+	entries, err := os.ReadDir(dir)
+	if dir == "" {
+		entries, err = os.ReadDir(".")
+	}
+	if err != nil {
+		return nil
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		if entry.IsDir() {
+			names = append(names, dir+name+"/")
+		} else if !dirsOnly {
+			names = append(names, dir+name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// matchPrefix returns the sorted, deduplicated list of candidates that start
+// with the supplied prefix, ignoring case.
+func matchPrefix(prefix string, candidates []string) (matches []string) {
+	for _, c := range candidates {
+		if len(c) >= len(prefix) && strings.EqualFold(c[:len(prefix)], prefix) {
+			matches = append(matches, c)
+		}
+	}
+	slices.Sort(matches)
+	return slices.Compact(matches)
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/rothskeller/packet-shell/cio"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	completionSlug = `Print a shell completion script`
+	completionHelp = `
+usage: packet completion bash|zsh|fish
+
+The "completion" command prints a script that enables completion of "packet" command lines in the named shell.  Once the script is loaded, pressing Tab after "packet" completes command names, message IDs, field names, message types, and bulletin areas, just as it does in the packet shell.  The completions come from the "packet" program itself, so they reflect the messages in the current directory at the time Tab is pressed.
+
+To load the completions in every new shell:
+  bash  ⇥add ` + "`" + `source <(packet completion bash)` + "`" + ` to ~/.bashrc
+  zsh   ⇥add ` + "`" + `source <(packet completion zsh)` + "`" + ` to ~/.zshrc, after compinit is run
+  fish  ⇥run ` + "`" + `packet completion fish > ~/.config/fish/completions/packet.fish` + "`" + `
+`
+)
+
+const bashCompletion = `# bash completion for packet
+_packet() {
+	local IFS=$'\n'
+	COMPREPLY=($(packet __complete "${COMP_WORDS[@]:1:COMP_CWORD}" 2>/dev/null))
+	if [[ ${#COMPREPLY[@]} -eq 1 && ${COMPREPLY[0]} == */ ]]; then
+		compopt -o nospace
+	fi
+}
+complete -o default -F _packet packet
+`
+
+const zshCompletion = `#compdef packet
+# zsh completion for packet
+_packet() {
+	local -a candidates
+	candidates=("${(@f)$(packet __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+	candidates=(${candidates:#})
+	(( ${#candidates} )) && compadd -Q -a candidates
+}
+compdef _packet packet
+`
+
+const fishCompletion = `# fish completion for packet
+function __packet_complete
+	set -l tokens (commandline -opc) (commandline -ct)
+	packet __complete $tokens[2..-1] 2>/dev/null
+end
+complete -c packet -f -a '(__packet_complete)'
+`
+
+func cmdCompletion(args []string) (err error) {
+	flags := pflag.NewFlagSet("completion", pflag.ContinueOnError)
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"completion"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(completionHelp)
+	}
+	if flags.NArg() != 1 {
+		return usage(completionHelp)
+	}
+	switch flags.Arg(0) {
+	case "bash":
+		io.WriteString(os.Stdout, bashCompletion)
+	case "zsh":
+		io.WriteString(os.Stdout, zshCompletion)
+	case "fish":
+		io.WriteString(os.Stdout, fishCompletion)
+	default:
+		return fmt.Errorf("unsupported shell %q", flags.Arg(0))
+	}
+	return nil
+}
+
+// cmdComplete is the hidden "__complete" command called by the shell
+// completion scripts.  Its arguments are the words of the command line after
+// "packet", the last of which is the (possibly empty) word being completed.  It
+// prints the candidate completions for that word, one per line.
+func cmdComplete(args []string) error {
+	var (
+		candidates []string
+		word       string
+	)
+	// Skip over any global options.
+	for len(args) > 1 {
+		if args[0] == "--script" || args[0] == "--no-script" || strings.HasPrefix(args[0], "--format=") {
+			args = args[1:]
+		} else if args[0] == "--format" && len(args) > 2 {
+			args = args[2:]
+		} else {
+			break
+		}
+	}
+	if len(args) != 0 {
+		word = args[len(args)-1]
+	}
+	if len(args) == 1 && strings.HasPrefix(word, "-") {
+		candidates = matchPrefix(word, []string{"--format", "--no-script", "--script"})
+	} else if len(args) == 2 && args[0] == "--format" {
+		candidates = matchPrefix(word, []string{"json", "text"})
+	} else {
+		_, candidates = completeCommandLine(strings.Join(args, " "))
+	}
+	for _, c := range candidates {
+		fmt.Println(c)
+	}
+	return nil
+}
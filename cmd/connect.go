@@ -6,11 +6,14 @@ import (
 	"io"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/rothskeller/packet-shell/agwpe"
 	"github.com/rothskeller/packet-shell/cio"
 	"github.com/rothskeller/packet-shell/config"
+	"github.com/rothskeller/packet-shell/kiss"
 	"github.com/rothskeller/packet/envelope"
 	"github.com/rothskeller/packet/incident"
 	"github.com/rothskeller/packet/jnos"
@@ -27,6 +30,8 @@ const (
 	connectSlug = `Connect to the BBS to send and/or receive messages`
 	connectHelp = `
 usage: packet connect [flags]
+  -b, --bbs «bbs»  ⇥connect to «bbs» only
+      --dry-run    ⇥show what would be done, without connecting
   -i, --immediate  ⇥immediate messages only
   -r, --receive    ⇥receiving incoming messages
   -s, --send       ⇥send queued messages
@@ -36,18 +41,34 @@ The "connect" (or "c") command makes a connection to the BBS and sends and/or re
 
 When receiving messages without the --immediate flag, any scheduled bulletin checks are performed as well.  (See the "packet bulletins" command for scheduling of bulletin checks.)
 
+The "connect" command tries the primary BBS connection first, and falls back to each backup connection in turn if the previous ones cannot be reached.  (See "packet help bbs" for how to set up backup connections.)  With the --bbs flag, it uses only the connection to the named «bbs».
+
+With the --dry-run flag, the "connect" command does not make a connection.  Instead, it shows what the connection would do:  the BBS it would connect to; the messages it would send, in order, with their sizes and estimated airtime at 1200 baud; whether it would read private messages; and the bulletin areas it would check.  It also checks each message to be sent, and reports any problems with them.
+
 The "connect" command lists all messages sent and received, except for receipts.  Run "packet help list" for details of the output format.
 `
 )
 
 type connection struct {
 	tosend        []string
+	readReceipts  []string
 	rcvlevel      int
 	subjectToLMI  map[string]string
 	areas         map[string]*config.BulletinConfig
 	haveBulletins map[string]map[string]bool
 	sigintch      chan os.Signal
+	profiles      []*config.BBSProfile
+	bbs           *config.BBSProfile
 	conn          *jnos.Conn
+	journal       *journal
+	stale         map[int]string
+	unattended    bool
+	dryRun        bool
+	interrupted   bool
+	sent          int
+	received      int
+	bytesSent     int
+	bytesReceived int
 }
 
 var ErrInterrupted = errors.New("connection interrupted by Ctrl-C")
@@ -58,6 +79,7 @@ func cmdConnect(args []string) (err error) {
 		receive   bool
 		immediate bool
 		verbose   bool
+		bbs       string
 		sendlevel int
 		conn      connection
 		flags     = pflag.NewFlagSet("connect", pflag.ContinueOnError)
@@ -66,6 +88,8 @@ func cmdConnect(args []string) (err error) {
 	flags.BoolVarP(&receive, "receive", "r", false, "receive incoming messages")
 	flags.BoolVarP(&immediate, "immediate", "i", false, "immediate messages only")
 	flags.BoolVarP(&verbose, "verbose", "v", false, "show BBS conversation")
+	flags.StringVarP(&bbs, "bbs", "b", "", "connect to «bbs» only")
+	flags.BoolVar(&conn.dryRun, "dry-run", false, "show what would be done, without connecting")
 	flags.Usage = func() {} // we do our own
 	if err = flags.Parse(args); err == pflag.ErrHelp {
 		return cmdHelp([]string{"connect"})
@@ -88,24 +112,35 @@ func cmdConnect(args []string) (err error) {
 	if immediate {
 		sendlevel, conn.rcvlevel = sendlevel*2, conn.rcvlevel*2
 	}
+	return conn.session(sendlevel, bbs, verbose)
+}
+
+// session runs a single BBS connection session.  It sends queued messages if
+// sendlevel is 1 (or only immediate ones if it is 2), and receives messages
+// according to c.rcvlevel.  If bbs is not empty, only the connection to that
+// BBS is tried.
+func (c *connection) session(sendlevel int, bbs string, verbose bool) (err error) {
 	// If we're checking bulletins, make a map of the areas to check based
 	// on time elapsed and requested frequency.
-	if conn.rcvlevel == 1 {
-		conn.areas = make(map[string]*config.BulletinConfig)
+	if c.rcvlevel == 1 {
+		c.areas = make(map[string]*config.BulletinConfig)
 		for area, bc := range config.C.Bulletins {
 			if time.Since(bc.LastCheck) >= bc.Frequency {
-				conn.areas[area] = bc
+				c.areas[area] = bc
 			}
 		}
 	}
 	// Scan through all existing messages, gathering data that we will need
 	// to handle the connection
-	conn.tosend, conn.subjectToLMI, conn.haveBulletins = preConnectScan(sendlevel, conn.areas)
+	c.tosend, c.subjectToLMI, c.haveBulletins = preConnectScan(sendlevel, c.areas)
+	if sendlevel == 1 {
+		c.readReceipts = pendingReadReceipts()
+	}
 	// Do we have anything to do?
-	if len(conn.tosend) == 0 && conn.rcvlevel == 0 {
+	if len(c.tosend) == 0 && len(c.readReceipts) == 0 && c.rcvlevel == 0 {
 		return errors.New("nothing to send")
 	}
-	if !haveConnectConfig() && cio.InputIsTerm && cio.OutputIsTerm {
+	if (!haveConnectConfig() || (bbs == "" && len(usableProfiles()) == 0)) && cio.InputIsTerm && cio.OutputIsTerm && !c.unattended {
 		cio.Confirm("Please provide necessary configuration settings for connection:")
 		if err = run([]string{"edit", "config"}); err != nil {
 			return err
@@ -114,26 +149,49 @@ func cmdConnect(args []string) (err error) {
 	if !haveConnectConfig() {
 		return errors.New("missing necessary configuration settings")
 	}
+	// Determine which BBS connections to try.
+	if bbs != "" {
+		if p := config.C.FindProfile(bbs); p == nil {
+			return fmt.Errorf("no connection configured for BBS %q", bbs)
+		} else if !haveProfileConfig(p) {
+			return fmt.Errorf("connection settings for BBS %s are incomplete", p.BBS)
+		} else {
+			c.profiles = []*config.BBSProfile{p}
+		}
+	} else {
+		c.profiles = usableProfiles()
+	}
+	if len(c.profiles) == 0 {
+		return errors.New("missing necessary configuration settings")
+	}
+	if c.dryRun {
+		c.printPlan()
+		return nil
+	}
 	// Run the connection.
 	defer cio.Status("")
-	if err := conn.run(verbose); err != nil {
+	if err := c.run(verbose); err != nil {
 		return err
 	}
-	// Save the configuration.  It may have new unread messages or new
-	// LastCheck times for the bulletin areas.
+	// Save the configuration.  It may have new unread messages, new
+	// LastCheck times for the bulletin areas, or changes to the send queue.
 	config.SaveConfig()
+	warnOverdue()
 	return nil
 }
 
 // preConnectScan scans all existing messages gathering information needed prior
 // to a connection.  It returns the list of messages to send, a map from subject
 // line to LMI for already-received direct messages, and a map from bulletin
-// area to the set of bulletin subjects already retrieved from that area.
+// area to the set of bulletin subjects already retrieved from that area.  The
+// list of messages to send is in the order they should be sent.
 func preConnectScan(sendlevel int, areas map[string]*config.BulletinConfig) (
 	tosend []string, subjectToLMI map[string]string, haveBulletins map[string]map[string]bool,
 ) {
-	var lmis []string
-
+	var (
+		lmis   []string
+		queued = make(map[string]string)
+	)
 	subjectToLMI = make(map[string]string)
 	haveBulletins = make(map[string]map[string]bool)
 	lmis, _ = incident.AllLMIs()
@@ -148,11 +206,7 @@ func preConnectScan(sendlevel int, areas map[string]*config.BulletinConfig) (
 			// Subject lines are truncated to 35 characters and then
 			// trimmed by the JNOS list command, so that's what
 			// we'll record.
-			subject := env.SubjectLine
-			if len(subject) > 35 {
-				subject = subject[:35]
-			}
-			subject = strings.TrimSpace(subject)
+			subject := listSubject(env.SubjectLine)
 			if haveBulletins[env.ReceivedArea] == nil {
 				haveBulletins[env.ReceivedArea] = make(map[string]bool)
 			}
@@ -167,37 +221,66 @@ func preConnectScan(sendlevel int, areas map[string]*config.BulletinConfig) (
 			// It's a message queued to be sent.  Add it to the list
 			// to be sent (but limit it to immediate messages only
 			// if that was requested).
-			if sendlevel == 2 {
-				if _, _, handling, _, _ := message.DecodeSubject(env.SubjectLine); handling != "I" {
-					return
-				}
+			_, _, handling, _, _ := message.DecodeSubject(env.SubjectLine)
+			queued[lmi] = handling
+			if sendlevel == 2 && handling != "I" {
+				continue
 			}
 			if sendlevel != 0 {
 				tosend = append(tosend, lmi)
 			}
 		}
 	}
+	// Put the messages to be sent in the order they should be sent.
+	sortSendQueue(tosend, queued, syncQueue(queued))
 	return
 }
 
-// haveConnectConfig returns whether we have all of the necessary config
-// settings to make a connection to the server.
+// haveConnectConfig returns whether we have the necessary config settings,
+// other than those of the BBS connection profiles, to make a connection to the
+// server.
 func haveConnectConfig() bool {
-	if config.C.BBSAddress == "" || config.C.OpCall == "" || config.C.RxMessageID == "" {
+	return config.C.OpCall != "" && config.C.RxMessageID != ""
+}
+
+// usableProfiles returns the BBS connection profiles that have all of the
+// necessary settings to make a connection, in the order they should be tried.
+func usableProfiles() (profiles []*config.BBSProfile) {
+	for _, p := range config.C.Profiles() {
+		if haveProfileConfig(p) {
+			profiles = append(profiles, p)
+		}
+	}
+	return profiles
+}
+
+// haveProfileConfig returns whether the specified BBS connection profile has
+// all of the necessary settings to make a connection.
+func haveProfileConfig(p *config.BBSProfile) bool {
+	if p.BBSAddress == "" {
 		return false
 	}
-	if strings.Contains(config.C.BBSAddress, ":") {
-		if config.C.Password == "" {
+	if strings.Contains(p.BBSAddress, ":") {
+		if p.Password == "" {
 			return false
 		}
-	} else {
-		if config.C.SerialPort == "" {
+	} else if p.TNCType != "KISS/TCP" && p.TNCType != "AGWPE" {
+		if p.SerialPort == "" {
 			return false
 		}
 	}
 	return true
 }
 
+// configInt returns the value of a numeric configuration setting, or the
+// supplied default if the setting is empty or invalid.
+func configInt(s string, def int) int {
+	if n, err := strconv.Atoi(s); err == nil && n >= 0 {
+		return n
+	}
+	return def
+}
+
 // run connects to the BBS and performs the desired operations.  It sends all of
 // the messages whose filenames are in the tosend array.  If rcvlevel is 2, it
 // receives immediate messages.  If rcvlevel is 1, it receives all incoming
@@ -208,16 +291,28 @@ func (c *connection) run(verbose bool) (err error) {
 		mailbox string
 		logfile *os.File
 		log     io.Writer
+		entries []*journalEntry
+		session *sessionRecord
 	)
 	// Intercept ^C so we can close the connection gracefully.
 	c.sigintch = make(chan os.Signal, 10)
 	signal.Notify(c.sigintch, os.Interrupt)
 	defer c.drainSigInt()
-	// Append to the log file.
-	if logfile, err = os.OpenFile("packet.log", os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666); err != nil {
+	// Append to the log file, and start the session record.
+	rotateLog()
+	if logfile, err = os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666); err != nil {
 		return err
 	}
 	defer logfile.Close()
+	session = startSession(logfile)
+	defer func() { c.endSession(session, logfile, err) }()
+	// Open the journal, noting any operations left over from an
+	// interrupted connection.
+	if c.journal, entries, err = openJournal(); err != nil {
+		return err
+	}
+	defer func() { c.journal.close(err == nil) }()
+	c.stale = make(map[int]string)
 	if verbose {
 		log = io.MultiWriter(logfile, os.Stdout)
 		cio.SuppressStatus = true
@@ -231,14 +326,21 @@ func (c *connection) run(verbose bool) (err error) {
 	} else {
 		mailbox = config.C.OpCall
 	}
-	cio.Status("Connecting to %s@%s...", mailbox, config.C.BBS)
-	if strings.IndexByte(config.C.BBSAddress, ':') >= 0 { // internet connection
-		c.conn, err = telnet.Connect(config.C.BBSAddress, mailbox, config.C.Password, log)
-	} else { // radio connection
-		c.conn, err = kpc3plus.Connect(config.C.SerialPort, config.C.BBSAddress, mailbox, config.C.OpCall, log)
+	for i, p := range c.profiles {
+		c.bbs = p
+		cio.Status("Connecting to %s@%s...", mailbox, p.BBS)
+		if c.conn, err = dialBBS(p, mailbox, log); err == nil {
+			break
+		}
+		err = fmt.Errorf("JNOS connect to %s: %s", p.BBS, err)
+		if i < len(c.profiles)-1 {
+			fmt.Fprintf(log, "[%s; trying %s]\n", err, c.profiles[i+1].BBS)
+			cio.Confirm("NOTE: %s; trying %s", err, c.profiles[i+1].BBS)
+		}
 	}
 	if err != nil {
-		return fmt.Errorf("JNOS connect: %s", err)
+		runHook(hookConnectFailed, hookInfo{BBS: c.bbs.BBS, Error: err.Error()})
+		return err
 	}
 	defer func() {
 		cio.Status("Closing connection...")
@@ -246,6 +348,9 @@ func (c *connection) run(verbose bool) (err error) {
 			err = fmt.Errorf("JNOS close: %s", err2)
 		}
 	}()
+	if err = c.reconcile(entries); err != nil {
+		return fmt.Errorf("reconcile interrupted connection: %s", err)
+	}
 	if err = c.sendMessages(); err != nil {
 		return fmt.Errorf("send messages: %s", err)
 	}
@@ -266,7 +371,27 @@ func (c *connection) run(verbose bool) (err error) {
 	return nil
 }
 
-// sendMessages sends the listed messages.
+// dialBBS opens a connection to the BBS described by the specified profile.
+func dialBBS(p *config.BBSProfile, mailbox string, log io.Writer) (*jnos.Conn, error) {
+	switch {
+	case strings.IndexByte(p.BBSAddress, ':') >= 0: // internet connection
+		return telnet.Connect(p.BBSAddress, mailbox, p.Password, log)
+	case p.TNCType == "KISS": // radio connection through KISS TNC
+		return kiss.Connect(p.SerialPort, configInt(p.KISSBaud, kiss.DefaultBaud),
+			configInt(p.KISSTxDelay, kiss.DefaultTxDelay), configInt(p.KISSPersist, kiss.DefaultPersist),
+			p.BBSAddress, mailbox, config.C.OpCall, log)
+	case p.TNCType == "KISS/TCP": // radio connection through soundcard modem
+		return kiss.ConnectTCP(p.TNCAddress(8001), p.BBSAddress, mailbox, config.C.OpCall, log)
+	case p.TNCType == "AGWPE": // radio connection through AGWPE server
+		return agwpe.Connect(p.TNCAddress(agwpe.DefaultPort), configInt(p.AGWRadioPort, 0),
+			p.BBSAddress, mailbox, config.C.OpCall, log)
+	default: // radio connection
+		return kpc3plus.Connect(p.SerialPort, p.BBSAddress, mailbox, config.C.OpCall, log)
+	}
+}
+
+// sendMessages sends the listed messages, followed by the queued read
+// receipts.
 func (c *connection) sendMessages() (err error) {
 	for _, lmi := range c.tosend {
 		env, msg, err := incident.ReadMessage(lmi)
@@ -277,26 +402,35 @@ func (c *connection) sendMessages() (err error) {
 			return fmt.Errorf("send %s: %s", lmi, err)
 		}
 	}
+	for _, lmi := range c.readReceipts {
+		env, msg, err := incident.ReadMessage(lmi + ".RR0")
+		if err != nil {
+			return fmt.Errorf("send read receipt for %s: read receipt: %s", lmi, err)
+		}
+		if err = c.sendMessage(lmi+".RR", env, msg); err != nil {
+			return fmt.Errorf("send read receipt for %s: %s", lmi, err)
+		}
+	}
 	return nil
 }
 
-// sendMessage sends a single message.  It is used for both outgoing human
-// messages and delivery receipts.
+// sendMessage sends a single message.  It is used for outgoing human messages,
+// delivery receipts (with a ".DR" suffix on the filename), and read receipts
+// (with a ".RR" suffix).
 func (c *connection) sendMessage(filename string, env *envelope.Envelope, msg message.Message) (err error) {
 	if c.checkSigInt() {
 		return ErrInterrupted
 	}
-	if config.C.TacCall != "" {
-		env.From = (&envelope.Address{Name: config.C.TacName, Address: strings.ToLower(config.C.TacCall + "@" + config.C.BBS + ".ampr.org")}).String()
-	} else {
-		env.From = (&envelope.Address{Name: config.C.OpName, Address: strings.ToLower(config.C.OpCall + "@" + config.C.BBS + ".ampr.org")}).String()
-	}
-	env.Date = time.Now()
-	msg.SetOperator(config.C.OpCall, config.C.OpName, false)
+	stampMessage(env, msg, c.bbs.BBS)
 	body := msg.EncodeBody()
-	if strings.HasSuffix(filename, ".DR") {
-		cio.Status("Sending delivery receipt for %s...", filename[:len(filename)-3])
-	} else {
+	lmi, isDR := strings.CutSuffix(filename, ".DR")
+	lmi, isRR := strings.CutSuffix(lmi, ".RR")
+	switch {
+	case isDR:
+		cio.Status("Sending delivery receipt for %s...", lmi)
+	case isRR:
+		cio.Status("Sending read receipt for %s...", lmi)
+	default:
 		cio.Status("Sending %s...", filename)
 	}
 	var to []string
@@ -312,29 +446,58 @@ func (c *connection) sendMessage(filename string, env *envelope.Envelope, msg me
 			to[i] = a.Address
 		}
 	}
+	if err = c.journal.record(journalEntry{Op: journalSendStart, LMI: filename}); err != nil {
+		return err
+	}
+	rendered := env.RenderBody(body)
 	if env.Bulletin {
-		err = c.conn.SendBulletin(env.SubjectLine, env.RenderBody(body), to[0])
+		err = c.conn.SendBulletin(env.SubjectLine, rendered, to[0])
 	} else {
-		err = c.conn.Send(env.SubjectLine, env.RenderBody(body), to...)
+		err = c.conn.Send(env.SubjectLine, rendered, to...)
 	}
 	if err != nil {
 		return fmt.Errorf("JNOS send: %s", err)
 	}
-	if strings.HasSuffix(filename, ".DR") {
-		if err = incident.SaveReceipt(filename[:len(filename)-3], env, msg); err != nil {
-			return fmt.Errorf("save receipt %s: %s", filename, err)
-		}
+	c.bytesSent += len(env.SubjectLine) + len(rendered)
+	if isDR {
+		err = c.journal.record(journalEntry{Op: journalDRSent, LMI: filename})
 	} else {
-		if err = incident.SaveMessage(filename, "", env, msg, false, false); err != nil {
-			return fmt.Errorf("save message %s: %s", filename, err)
+		err = c.journal.record(journalEntry{Op: journalSendAck, LMI: filename})
+	}
+	if err != nil {
+		return err
+	}
+	if isDR || isRR {
+		env.ReadyToSend = false
+		if err = incident.SaveReceipt(lmi, env, msg); err != nil {
+			return fmt.Errorf("save receipt %s: %s", filename, err)
 		}
+		return nil
 	}
-	if !strings.HasSuffix(filename, ".DR") {
-		cio.ListMessage(listItemForMessage(filename, "", env))
+	if err = incident.SaveMessage(filename, "", env, msg, false, false); err != nil {
+		return fmt.Errorf("save message %s: %s", filename, err)
 	}
+	cio.ListMessage(listItemForMessage(filename, "", env))
+	noteUnqueued(filename)
+	c.sent++
+	hi := messageHookInfo(filename, env, msg)
+	hi.BBS = c.bbs.BBS
+	runHook(hookSent, hi)
 	return nil
 }
 
+// stampMessage sets the sender, date, and operator of a message that is about to
+// be sent through the specified BBS.
+func stampMessage(env *envelope.Envelope, msg message.Message, bbs string) {
+	if config.C.TacCall != "" {
+		env.From = (&envelope.Address{Name: config.C.TacName, Address: strings.ToLower(config.C.TacCall + "@" + bbs + ".ampr.org")}).String()
+	} else {
+		env.From = (&envelope.Address{Name: config.C.OpName, Address: strings.ToLower(config.C.OpCall + "@" + bbs + ".ampr.org")}).String()
+	}
+	env.Date = time.Now()
+	msg.SetOperator(config.C.OpCall, config.C.OpName, false)
+}
+
 // receiveMessages receives all messages in the current mailbox (i.e., the one
 // we connected to).
 func (c *connection) receiveMessages() (err error) {
@@ -365,6 +528,16 @@ func (c *connection) receiveMessage(area string, msgnum int) (done bool, err err
 	if c.checkSigInt() {
 		return false, ErrInterrupted
 	}
+	// If the message was already received during an interrupted
+	// connection, just kill it.
+	if lmi, ok := c.stale[msgnum]; ok && area == "" {
+		cio.Status("Removing message %d from BBS...", msgnum)
+		if err = c.conn.Kill(msgnum); err != nil {
+			return false, fmt.Errorf("JNOS kill %d: %s", msgnum, err)
+		}
+		delete(c.stale, msgnum)
+		return false, c.journal.record(journalEntry{Op: journalKilled, LMI: lmi})
+	}
 	// Read the message.
 	if area != "" {
 		cio.Status("Reading message %d in %s...", msgnum, area)
@@ -378,20 +551,50 @@ func (c *connection) receiveMessage(area string, msgnum int) (done bool, err err
 	if raw == "" {
 		return true, nil
 	}
+	c.bytesReceived += len(raw)
 	// Record receipt of the message.
 	lmi, env, msg, oenv, omsg, err := incident.ReceiveMessage(
-		raw, config.C.BBS, area, config.C.RxMessageID, config.C.OpCall, config.C.OpName)
+		raw, c.bbs.BBS, area, config.C.RxMessageID, config.C.OpCall, config.C.OpName)
 	if errors.As(err, &w) {
 		cio.Confirm("WARNING: %s has fields that are invalid for its type and version", lmi)
 	} else if err != nil {
 		return false, err
 	}
+	if lmi != "" {
+		// Only a received human message needs a delivery receipt sent.
+		// For a received delivery receipt, oenv is the envelope of our
+		// own sent message, not a receipt to be sent.
+		var needDR bool
+		switch msg.(type) {
+		case nil, *readrcpt.ReadReceipt, *delivrcpt.DeliveryReceipt:
+			break
+		default:
+			needDR = oenv != nil
+		}
+		err = c.journal.record(journalEntry{Op: journalReceived, LMI: lmi, BBS: c.bbs.BBS, Subject: env.SubjectLine, Area: area, NeedDR: needDR})
+		if err != nil {
+			return false, err
+		}
+	}
 	// Received receipts are handled differently than other messages.
 	switch msg := msg.(type) {
 	case nil:
 		// ignore message (e.g. autoresponse)
 	case *readrcpt.ReadReceipt:
-		// ignore read receipts
+		subject := msg.MessageSubject
+		if subject == "" {
+			subject = strings.TrimPrefix(env.SubjectLine, "READ: ")
+		}
+		if olmi := c.subjectToLMI[subject]; olmi == "" {
+			cio.Confirm("NOTE: discarding read receipt for unknown message %q", subject)
+		} else if err = saveReadReceipt(olmi, raw); err != nil {
+			return false, fmt.Errorf("save read receipt for %s: %s", olmi, err)
+		} else if oenv, _, err := incident.ReadMessage(olmi); err == nil {
+			// Display the fact that our message was read.
+			li := listItemForMessage(olmi, "", oenv)
+			li.Flag = "READ"
+			cio.ListMessage(li)
+		}
 	case *delivrcpt.DeliveryReceipt:
 		if lmi == "" {
 			cio.Confirm("NOTE: discarding receipt for unknown message %q", msg.MessageSubject)
@@ -400,6 +603,10 @@ func (c *connection) receiveMessage(area string, msgnum int) (done bool, err err
 			li := listItemForMessage(lmi, msg.LocalMessageID, oenv)
 			li.Flag = "HAVE RCPT"
 			cio.ListMessage(li)
+			_, sentmsg, _ := incident.ReadMessage(lmi)
+			hi := messageHookInfo(lmi, oenv, sentmsg)
+			hi.BBS = c.bbs.BBS
+			runHook(hookReceipt, hi)
 		}
 	default:
 		// Mark it unread.
@@ -410,6 +617,18 @@ func (c *connection) receiveMessage(area string, msgnum int) (done bool, err err
 		}
 		li := listItemForMessage(lmi, rmi, env)
 		cio.ListMessage(li)
+		c.received++
+		hi := messageHookInfo(lmi, env, msg)
+		hi.BBS = c.bbs.BBS
+		if area != "" {
+			runHook(hookBulletin, hi)
+		} else {
+			runHook(hookReceived, hi)
+			if hi.Handling == "I" {
+				runHook(hookReceivedImmediate, hi)
+				cio.AlertImmediate(lmi)
+			}
+		}
 		// If we have oenv/omsg, it's a delivery receipt to be sent.
 		if oenv != nil {
 			if err = c.sendMessage(lmi+".DR", oenv, omsg); err != nil {
@@ -425,6 +644,11 @@ func (c *connection) receiveMessage(area string, msgnum int) (done bool, err err
 		if err = c.conn.Kill(msgnum); err != nil {
 			return false, fmt.Errorf("JNOS kill %d: %s", msgnum, err)
 		}
+		if lmi != "" {
+			if err = c.journal.record(journalEntry{Op: journalKilled, LMI: lmi}); err != nil {
+				return false, err
+			}
+		}
 	}
 	return false, nil
 }
@@ -531,7 +755,7 @@ func (c *connection) checkSigInt() (seen bool) {
 	for {
 		select {
 		case <-c.sigintch:
-			seen = true
+			seen, c.interrupted = true, true
 		default:
 			return
 		}
@@ -542,11 +766,11 @@ func (c *connection) checkSigInt() (seen bool) {
 // buffer, and closes the channel.
 func (c *connection) drainSigInt() {
 	var done bool
-	signal.Reset(os.Interrupt)
+	signal.Stop(c.sigintch)
 	for !done {
 		select {
 		case <-c.sigintch:
-			// no op
+			c.interrupted = true
 		default:
 			done = true
 		}
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rothskeller/packet-shell/config"
+	"github.com/rothskeller/packet-shell/simbbs"
+	"github.com/rothskeller/packet/envelope"
+	"github.com/rothskeller/packet/incident"
+	"github.com/rothskeller/packet/jnos/telnet"
+	"github.com/rothskeller/packet/message"
+	"github.com/rothskeller/packet/xscmsg"
+)
+
+// These tests run BBS connection sessions against the simulated BBS, with
+// each station's incident in its own temporary directory.
+
+func TestMain(m *testing.M) {
+	xscmsg.Register()
+	os.Exit(m.Run())
+}
+
+// startBBS starts a simulated BBS, named W6XSC, with mailboxes loaded from the
+// supplied files (which are keyed by "area/filename").  It returns the address
+// of the BBS.
+func startBBS(t *testing.T, files map[string]string) string {
+	var dir = t.TempDir()
+
+	for name, contents := range files {
+		fn := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fn), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server, err := simbbs.New("W6XSC", dir, nil)
+	if err != nil {
+		t.Fatalf("simbbs.New: %s", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve(l)
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().String()
+}
+
+// newStation creates an incident directory for a station with the specified
+// call sign and message ID prefix, configured to connect to the simulated BBS
+// at addr.  It makes that directory current, and returns it.
+func newStation(t *testing.T, call, msgid, addr string) (dir string) {
+	t.Setenv("HOME", t.TempDir()) // SaveConfig writes $HOME/.packet
+	if wd, err := os.Getwd(); err == nil {
+		t.Cleanup(func() {
+			os.Chdir(wd)
+			config.Reload()
+		})
+	}
+	dir = t.TempDir()
+	useStation(t, dir)
+	config.C.OpCall = call
+	config.C.OpName = "Test Operator"
+	config.C.RxMessageID = msgid
+	config.C.TxMessageID = msgid
+	config.C.BBS = "W6XSC"
+	config.C.BBSAddress = addr
+	config.C.Password = "password"
+	config.C.TNCType = ""
+	config.SaveConfig()
+	return dir
+}
+
+// useStation makes the specified station's incident directory current.
+func useStation(t *testing.T, dir string) {
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	config.Reload()
+}
+
+// queueMessage creates a plain text message and queues it for sending.
+func queueMessage(t *testing.T, lmi, to, subject string) {
+	msg := message.Create("plain", "")
+	if msg == nil {
+		t.Fatal("no plain text message type")
+	}
+	mb := msg.Base()
+	*mb.FOriginMsgID = lmi
+	*mb.FHandling = "ROUTINE"
+	*mb.FSubject = subject
+	*mb.FBody = "This is a test."
+	env := &envelope.Envelope{To: to, ReadyToSend: true}
+	if err := incident.SaveMessage(lmi, "", env, msg, false, false); err != nil {
+		t.Fatalf("save %s: %s", lmi, err)
+	}
+	noteQueued(lmi)
+}
+
+// connectSession runs a BBS connection session, as "packet connect" would.
+func connectSession(t *testing.T, sendlevel, rcvlevel int) {
+	t.Helper()
+	var c = connection{rcvlevel: rcvlevel, unattended: true}
+	if err := c.session(sendlevel, "", false); err != nil {
+		t.Fatalf("session: %s", err)
+	}
+}
+
+// receivedLMIs returns the LMIs of the received messages in the current
+// incident, mapped to their envelopes.
+func receivedLMIs(t *testing.T) map[string]*envelope.Envelope {
+	var received = make(map[string]*envelope.Envelope)
+
+	lmis, err := incident.AllLMIs()
+	if err != nil {
+		t.Fatalf("AllLMIs: %s", err)
+	}
+	for _, lmi := range lmis {
+		if env, _, err := incident.ReadMessage(lmi); err == nil && env.IsReceived() {
+			received[lmi] = env
+		}
+	}
+	return received
+}
+
+// bbsSubjects logs into the simulated BBS as the specified user and returns the
+// subjects of the messages in that user's mailbox.
+func bbsSubjects(t *testing.T, addr, user string) (subjects []string) {
+	t.Helper()
+	conn, err := telnet.Connect(addr, user, "password", io.Discard)
+	if err != nil {
+		t.Fatalf("telnet.Connect: %s", err)
+	}
+	defer conn.Close()
+	list, err := conn.List("")
+	if err != nil {
+		t.Fatalf("List: %s", err)
+	}
+	if list != nil {
+		for _, li := range list.Messages {
+			subjects = append(subjects, li.SubjectPrefix)
+		}
+	}
+	return subjects
+}
+
+// sendAndReceive has station A send a message to station B, and station B
+// receive it.  It returns the LMI under which B received it.
+func sendAndReceive(t *testing.T, addr, adir, bdir string) (blmi string) {
+	useStation(t, adir)
+	queueMessage(t, "AAA-101P", "kc6bbb@w6xsc.ampr.org", "Test message")
+	connectSession(t, 1, 0)
+	if env, _, err := incident.ReadMessage("AAA-101P"); err != nil {
+		t.Fatalf("read AAA-101P: %s", err)
+	} else if !env.IsFinal() || env.ReadyToSend {
+		t.Fatal("AAA-101P not marked sent")
+	}
+	if readQueueEntry("AAA-101P") != nil {
+		t.Error("AAA-101P still in send queue")
+	}
+	if subjects := bbsSubjects(t, addr, "kc6bbb"); len(subjects) != 1 || !strings.HasPrefix(subjects[0], "AAA-101P_R_") {
+		t.Fatalf("KC6BBB mailbox has %q, want AAA-101P", subjects)
+	}
+	useStation(t, bdir)
+	connectSession(t, 1, 1)
+	received := receivedLMIs(t)
+	if len(received) != 1 {
+		t.Fatalf("KC6BBB received %d messages, want 1", len(received))
+	}
+	for lmi, env := range received {
+		if !strings.HasPrefix(env.SubjectLine, "AAA-101P_R_") {
+			t.Errorf("KC6BBB received %q, want AAA-101P", env.SubjectLine)
+		}
+		blmi = lmi
+	}
+	if !config.C.Unread[blmi] {
+		t.Errorf("%s not marked unread", blmi)
+	}
+	if subjects := bbsSubjects(t, addr, "kc6bbb"); len(subjects) != 0 {
+		t.Errorf("KC6BBB mailbox has %q after receipt, want empty", subjects)
+	}
+	if _, err := os.Stat(journalFile); err == nil {
+		t.Errorf("%s left behind after successful connection", journalFile)
+	}
+	return blmi
+}
+
+func TestConnectSendReceive(t *testing.T) {
+	addr := startBBS(t, nil)
+	adir := newStation(t, "KC6AAA", "AAA-101P", addr)
+	bdir := newStation(t, "KC6BBB", "BBB-201P", addr)
+	blmi := sendAndReceive(t, addr, adir, bdir)
+
+	// Station B sent a delivery receipt to station A.
+	subjects := bbsSubjects(t, addr, "kc6aaa")
+	if len(subjects) != 1 || !strings.HasPrefix(subjects[0], "DELIVERED: AAA-101P") {
+		t.Fatalf("KC6AAA mailbox has %q, want delivery receipt", subjects)
+	}
+	// Station A receives it and records the delivery.
+	useStation(t, adir)
+	connectSession(t, 1, 1)
+	if received := receivedLMIs(t); len(received) != 0 {
+		t.Errorf("KC6AAA received %d messages, want only a receipt", len(received))
+	}
+	delivs, err := incident.Deliveries("AAA-101P")
+	if err != nil {
+		t.Fatalf("Deliveries: %s", err)
+	}
+	if len(delivs) != 1 || delivs[0].RemoteMessageID != blmi {
+		t.Errorf("AAA-101P deliveries %+v, want one to %s", delivs, blmi)
+	}
+	if subjects := bbsSubjects(t, addr, "kc6aaa"); len(subjects) != 0 {
+		t.Errorf("KC6AAA mailbox has %q after receipt, want empty", subjects)
+	}
+}
+
+func TestConnectBulletins(t *testing.T) {
+	addr := startBBS(t, map[string]string{
+		"xscevent/1": "From: kc6xyz@w6xsc.ampr.org\nTo: xscevent@allxsc\nSubject: XYZ-001P_R_Exercise starts at noon\nDate: Mon, 12 Oct 2026 10:00:00 -0700\n\nThe exercise starts at noon.\n",
+	})
+	newStation(t, "KC6BBB", "BBB-201P", addr)
+	config.C.Bulletins = map[string]*config.BulletinConfig{"xscevent": {Frequency: time.Hour}}
+	config.SaveConfig()
+	connectSession(t, 0, 1)
+	received := receivedLMIs(t)
+	if len(received) != 1 {
+		t.Fatalf("received %d bulletins, want 1", len(received))
+	}
+	for _, env := range received {
+		if env.ReceivedArea != "xscevent" || !strings.HasPrefix(env.SubjectLine, "XYZ-001P_R_") {
+			t.Errorf("received %q in area %q, want XYZ-001P in xscevent", env.SubjectLine, env.ReceivedArea)
+		}
+	}
+	if bc := config.C.Bulletins["xscevent"]; bc == nil || time.Since(bc.LastCheck) > time.Minute {
+		t.Error("bulletin check time not recorded")
+	}
+	// A second check doesn't fetch the same bulletin again.
+	config.C.Bulletins["xscevent"].LastCheck = time.Time{}
+	config.SaveConfig()
+	connectSession(t, 0, 1)
+	if received = receivedLMIs(t); len(received) != 1 {
+		t.Errorf("have %d bulletins after second check, want 1", len(received))
+	}
+}
+
+// TestConnectInterruptedReceipt checks that a delivery receipt received during
+// an interrupted connection is not answered with a delivery receipt of our own
+// when the journal is reconciled.
+func TestConnectInterruptedReceipt(t *testing.T) {
+	addr := startBBS(t, nil)
+	adir := newStation(t, "KC6AAA", "AAA-101P", addr)
+	bdir := newStation(t, "KC6BBB", "BBB-201P", addr)
+	sendAndReceive(t, addr, adir, bdir)
+
+	// Station A receives the delivery receipt, but the connection is
+	// interrupted before it ends cleanly, leaving the journal behind.
+	useStation(t, adir)
+	var (
+		c   = connection{rcvlevel: 1, stale: make(map[int]string)}
+		err error
+	)
+	c.tosend, c.subjectToLMI, c.haveBulletins = preConnectScan(0, nil)
+	c.bbs = config.C.Primary()
+	if c.journal, _, err = openJournal(); err != nil {
+		t.Fatal(err)
+	}
+	if c.conn, err = dialBBS(c.bbs, config.C.OpCall, io.Discard); err != nil {
+		t.Fatalf("dialBBS: %s", err)
+	}
+	if _, err = c.receiveMessage("", 1); err != nil {
+		t.Fatalf("receiveMessage: %s", err)
+	}
+	c.journal.close(false)
+	c.conn.Close()
+	if _, err = os.Stat(journalFile); err != nil {
+		t.Fatalf("journal missing after interrupted connection: %s", err)
+	}
+
+	// The next connection reconciles the journal.  It sends only the newly
+	// queued message; in particular, it must not send a delivery receipt
+	// for the receipt.
+	queueMessage(t, "AAA-102P", "kc6bbb@w6xsc.ampr.org", "Second message")
+	connectSession(t, 1, 0)
+	if _, err = os.Stat(journalFile); err == nil {
+		t.Errorf("%s left behind after reconciliation", journalFile)
+	}
+	if subjects := bbsSubjects(t, addr, "kc6aaa"); len(subjects) != 0 {
+		t.Errorf("KC6AAA mailbox has %q, want empty", subjects)
+	}
+	if subjects := bbsSubjects(t, addr, "kc6bbb"); len(subjects) != 1 || !strings.HasPrefix(subjects[0], "AAA-102P_R_") {
+		t.Errorf("KC6BBB mailbox has %q, want only AAA-102P", subjects)
+	}
+}
+
+// TestConnectInterruptedSend checks that a message whose send was interrupted
+// before the BBS acknowledged it is taken out of the send queue rather than
+// sent again.
+func TestConnectInterruptedSend(t *testing.T) {
+	addr := startBBS(t, nil)
+	newStation(t, "KC6AAA", "AAA-101P", addr)
+	queueMessage(t, "AAA-101P", "kc6bbb@w6xsc.ampr.org", "Test message")
+	queueMessage(t, "AAA-102P", "kc6bbb@w6xsc.ampr.org", "Second message")
+	j, _, err := openJournal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = j.record(journalEntry{Op: journalSendStart, LMI: "AAA-101P"}); err != nil {
+		t.Fatal(err)
+	}
+	j.close(false)
+
+	connectSession(t, 1, 0)
+	env, _, err := incident.ReadMessage("AAA-101P")
+	if err != nil {
+		t.Fatalf("read AAA-101P: %s", err)
+	}
+	if env.IsFinal() || env.ReadyToSend {
+		t.Error("AAA-101P should be unsent and unqueued")
+	}
+	if readQueueEntry("AAA-101P") != nil {
+		t.Error("AAA-101P still has a queue entry")
+	}
+	if subjects := bbsSubjects(t, addr, "kc6bbb"); len(subjects) != 1 || !strings.HasPrefix(subjects[0], "AAA-102P_R_") {
+		t.Errorf("KC6BBB mailbox has %q, want only AAA-102P", subjects)
+	}
+}
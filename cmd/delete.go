@@ -49,6 +49,7 @@ func cmdDelete(args []string) (err error) {
 		}
 	}
 	incident.RemoveMessage(args[0])
+	noteUnqueued(args[0])
 	cio.Confirm("%s deleted.", args[0])
 	return nil
 }
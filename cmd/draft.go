@@ -56,6 +56,7 @@ func cmdDraft(args []string) (err error) {
 		if err = incident.SaveMessage(lmi, "", env, msg, false, false); err != nil {
 			return fmt.Errorf("saving %s: %s", lmi, err)
 		}
+		noteUnqueued(lmi)
 	}
 	li = listItemForMessage(lmi, "", env)
 	li.NoHeader = true
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rothskeller/packet-shell/config"
+	"github.com/rothskeller/packet/incident"
+)
+
+// Airtime estimation for --dry-run.  Data is sent at 1200 baud, i.e. 150
+// bytes per second.  AX.25 frame headers, acknowledgments, and transmitter
+// keyup delays add roughly a quarter to that.
+const (
+	airtimeBytesPerSecond = 150
+	airtimeOverhead       = 1.25
+)
+
+// printPlan prints the plan for a connection session, for --dry-run, instead of
+// making the connection.
+func (c *connection) printPlan() {
+	var (
+		mailbox    string
+		totalBytes int
+		names      []string
+	)
+	if config.C.TacCall != "" {
+		mailbox = config.C.TacCall
+	} else {
+		mailbox = config.C.OpCall
+	}
+	for _, p := range c.profiles {
+		names = append(names, fmt.Sprintf("%s (%s)", p.BBS, p.ConnType()))
+	}
+	fmt.Printf("Would connect to %s as %s.\n", strings.Join(names, ", or if that fails, "), mailbox)
+	if len(c.tosend) == 0 {
+		fmt.Println("Would send no messages.")
+	} else {
+		fmt.Printf("Would send %d message(s), in this order:\n", len(c.tosend))
+	}
+	for i, lmi := range c.tosend {
+		env, msg, err := incident.ReadMessage(lmi)
+		if err != nil {
+			fmt.Printf("%3d. %s  PROBLEM: %s\n", i+1, lmi, err)
+			continue
+		}
+		stampMessage(env, msg, c.profiles[0].BBS)
+		size := len(env.SubjectLine) + len(env.RenderBody(msg.EncodeBody()))
+		totalBytes += size
+		fmt.Printf("%3d. %s  %6d bytes  %6s  %s\n", i+1, lmi, size, airtime(size), env.SubjectLine)
+		if env.To == "" {
+			fmt.Printf("     PROBLEM: no To: address\n")
+		}
+		for _, problem := range messageProblems(msg) {
+			fmt.Printf("     PROBLEM: %s\n", problem)
+		}
+	}
+	if len(c.tosend) != 0 {
+		fmt.Printf("     Total %d bytes, about %s of airtime at 1200 baud.\n", totalBytes, airtime(totalBytes))
+	}
+	if len(c.readReceipts) != 0 {
+		fmt.Printf("Would send read receipts for %s.\n", strings.Join(c.readReceipts, ", "))
+	}
+	switch c.rcvlevel {
+	case 0:
+		fmt.Println("Would not read private messages.")
+	case 1:
+		fmt.Printf("Would read all private messages in the %s mailbox.\n", mailbox)
+	case 2:
+		fmt.Printf("Would read immediate private messages in the %s mailbox.\n", mailbox)
+	}
+	if len(c.areas) == 0 {
+		fmt.Println("Would not check any bulletin areas.")
+	} else {
+		var areas = make([]string, 0, len(c.areas))
+		for area := range c.areas {
+			areas = append(areas, area)
+		}
+		sort.Strings(areas)
+		fmt.Printf("Would check bulletin areas: %s.\n", strings.Join(areas, ", "))
+	}
+}
+
+// airtime returns the estimated airtime for sending the specified number of
+// bytes, formatted for display.
+func airtime(bytes int) string {
+	d := time.Duration(float64(bytes) / airtimeBytesPerSecond * airtimeOverhead * float64(time.Second))
+	return d.Round(time.Second).String()
+}
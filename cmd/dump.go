@@ -6,7 +6,6 @@ import (
 	"os"
 
 	"github.com/rothskeller/packet-shell/cio"
-	"github.com/rothskeller/packet-shell/config"
 	"github.com/spf13/pflag"
 )
 
@@ -43,9 +42,6 @@ func cmdDump(args []string) (err error) {
 	}
 	io.Copy(os.Stdout, fh)
 	fh.Close()
-	if config.C.Unread[lmi] {
-		config.C.Unread[lmi] = false
-		config.SaveConfig()
-	}
+	markRead(lmi)
 	return nil
 }
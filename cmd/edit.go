@@ -210,6 +210,7 @@ LOOP: // Run the editor loop.
 		}
 		if lmi != "" {
 			incident.RemoveMessage(lmi)
+			noteRenamed(lmi, newlmi)
 		}
 		lmi = newlmi
 	}
@@ -217,6 +218,12 @@ LOOP: // Run the editor loop.
 	if err = incident.SaveMessage(lmi, "", env, msg, false, false); err != nil {
 		return fmt.Errorf("saving %s: %s", lmi, err)
 	}
+	// Record any change to the send queue.
+	if env.ReadyToSend {
+		noteQueued(lmi)
+	} else {
+		noteUnqueued(lmi)
+	}
 	// Display the result.
 	cio.ListMessage(listItemForMessage(lmi, "", env))
 	if lmi == "config" {
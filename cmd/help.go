@@ -1,40 +1,56 @@
 package cmd
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"strings"
 
 	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet-shell/config"
 )
 
 const helpSlug = `Print help for packet commands or topics`
 const topHelp = `
-The "packet" command provides multiple commands for handling packet radio messages.  When invoked with a command on the command line, it runs that command.  When invoked without any arguments, it starts a shell that allows running multiple commands without the "packet" prefix on each.
+The "packet" command provides multiple commands for handling packet radio messages.  When invoked with a command on the command line, it runs that command.  The command can be preceded by "--script" or "--no-script" to force script-friendly or interactive behavior, and by "--format json" to get JSON output (see "packet help script").  When invoked without any arguments, it starts a shell that allows running multiple commands without the "packet" prefix on each.  In the shell, pressing Tab completes the command name, message ID, field name, message type, bulletin area, or file name being typed; if there are several possibilities, they are listed, and pressing Tab again cycles through them.
 
 Available commands include:
+  ack        ⇥` + ackSlug + `
+  alias      ⇥` + aliasSlug + `
+  bbs        ⇥` + bbsSlug + `
   bulletins  ⇥` + bulletinsSlug + `
   cd         ⇥` + chdirSlug + `
+  completion ⇥` + completionSlug + `
   connect    ⇥` + connectSlug + `
   delete     ⇥` + deleteSlug + `
   draft      ⇥` + draftSlug + `
   dump       ⇥` + dumpSlug + `
   edit       ⇥` + editSlug + `
   help       ⇥` + helpSlug + `
+  history    ⇥` + historySlug + `
   ics309     ⇥` + ics309Slug + `
   list       ⇥` + listSlug + `
+  log        ⇥` + logSlug + `
   new        ⇥` + newSlug + `
+  overdue    ⇥` + overdueSlug + `
   pdf        ⇥` + pdfSlug + `
   queue      ⇥` + queueSlug + `
   quit       ⇥` + quitSlug + `
+  resend     ⇥` + resendSlug + `
+  roster     ⇥` + rosterSlug + `
+  search     ⇥` + searchSlug + `
   set        ⇥` + setSlug + `
   show       ⇥` + showSlug + `
+  simbbs     ⇥` + simbbsSlug + `
+  source     ⇥` + sourceSlug + `
   version    ⇥` + versionSlug + `
+  watch      ⇥` + watchSlug + `
 For help on a command, run "packet help «command»".
 
 Additional help is available on the following topics:
   config     ⇥` + configSlug + `
   files      ⇥` + filesSlug + `
+  hooks      ⇥` + hooksSlug + `
   script     ⇥` + scriptSlug + `
   types      ⇥` + typesSlug + `
 For these topics, run "packet help «topic»".
@@ -47,12 +63,20 @@ func cmdHelp(args []string) (err error) {
 
 	if len(args) != 0 {
 		switch args[0] {
+		case "ack":
+			helpText = ackHelp
+		case "alias", "unalias":
+			helpText = aliasHelp
 		case "b", "bull", "bulletin", "bulletins":
 			helpText = bulletinsHelp
+		case "bbs":
+			helpText = bbsHelp
 		case "cd", "chdir", "md", "mkdir", "pwd":
 			helpText = chdirHelp
 		case "config":
 			helpText = configHelp
+		case "completion":
+			helpText = completionHelp
 		case "c", "connect":
 			helpText = connectHelp
 		case "delete":
@@ -65,35 +89,62 @@ func cmdHelp(args []string) (err error) {
 			helpText = editHelp
 		case "files":
 			helpText = filesHelp
+		case "hooks":
+			helpText = hooksHelp
+		case "history":
+			helpText = historyHelp
 		case "309", "ics309":
 			helpText = ics309Help
 		case "l", "list":
 			helpText = listHelp
+		case "log":
+			helpText = logHelp
 		case "n", "new":
 			helpText = newHelp
+		case "overdue":
+			helpText = overdueHelp
 		case "pdf":
 			helpText = pdfHelp
 		case "queue":
 			helpText = queueHelp
 		case "q", "quit", "exit":
 			helpText = quitHelp
+		case "resend":
+			helpText = resendHelp
+		case "roster":
+			helpText = rosterHelp
+		case "search":
+			helpText = searchHelp
 		case "script":
 			helpText = scriptHelp
 		case "set":
 			helpText = setHelp
 		case "s", "show":
 			helpText = showHelp
+		case "source", ".":
+			helpText = sourceHelp
+		case "simbbs":
+			helpText = simbbsHelp
 		case "types":
 			typesHelp() // special case, computed content
 			return nil
 		case "version":
 			helpText = versionHelp
+		case "watch":
+			helpText = watchHelp
 		default:
-			cio.Error("no such command or help topic %q", args[0])
+			if expansion, ok := config.C.Alias(args[0]); ok && !isBuiltinCommand(args[0]) {
+				helpText = fmt.Sprintf("%q is a user-defined alias for:\n    ⇥%s\n", args[0], expansion)
+			} else {
+				cio.Error("no such command or help topic %q", args[0])
+			}
 		}
 	}
 	if helpText == "" {
 		helpText = topHelp
+		if aliases := aliasHelpText(); aliases != "" {
+			helpText = strings.Replace(helpText, "\nAdditional help", "\n"+aliases+"\nAdditional help", 1)
+		}
 	}
 	helpText = strings.TrimLeft(helpText, "\n") // Allows newline after `
 	io.WriteString(os.Stdout, cio.WrapText(helpText))
@@ -0,0 +1,190 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet-shell/config"
+	"github.com/rothskeller/packet/message"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	historySlug = `List commands previously run in the shell`
+	historyHelp = `
+usage: packet history [--mine] [«count»]
+  -m, --mine  ⇥list only commands run by the current user
+
+The "history" command lists the commands previously run in the packet shell in this incident directory, with the time each was run and the operator call sign configured at the time (see "packet help config").  If «count» is given, only the most recent «count» commands are listed.  With the --mine (-m) flag, only commands run by the current login user are listed.  The history is kept in the "packet.history" file in the incident directory, so it is shared by all operators using that directory, and it survives restarts of the shell.  Commands that set hidden settings, such as the BBS password, are not kept in the history.
+
+In the shell, the up and down arrow keys recall the commands in the history, and Ctrl-R searches backward through it:  type part of a command to find the most recent command containing it, press Ctrl-R again to find older ones, press Enter to run the found command or any editing key to edit it, or press Esc to cancel the search.  By default, all commands run in the incident directory can be recalled.  If the PACKET_HISTORY environment variable is set to "user", only the commands run by the current login user can be recalled.
+`
+)
+
+// historyFile is the name of the file, in the incident directory, that contains
+// the shell command history, one JSON object per line.
+const historyFile = "packet.history"
+
+// historyRecallLimit is the maximum number of commands loaded from the history
+// file for recall on the shell command line.
+const historyRecallLimit = 1000
+
+// A historyRecord is a single command in the shell history.
+type historyRecord struct {
+	Time    time.Time
+	OpCall  string `json:",omitempty"`
+	User    string `json:",omitempty"`
+	Command string
+}
+
+func cmdHistory(args []string) (err error) {
+	var (
+		mine    bool
+		count   int
+		records []*historyRecord
+		items   []*cio.HistoryItem
+	)
+	flags := pflag.NewFlagSet("history", pflag.ContinueOnError)
+	flags.BoolVarP(&mine, "mine", "m", false, "list only commands run by the current user")
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"history"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(historyHelp)
+	}
+	switch flags.NArg() {
+	case 0:
+		break
+	case 1:
+		if count, err = strconv.Atoi(flags.Arg(0)); err != nil || count <= 0 {
+			cio.Error("%q is not a valid count", flags.Arg(0))
+			return usage(historyHelp)
+		}
+	default:
+		return usage(historyHelp)
+	}
+	if records, err = readHistory(mine); err != nil {
+		return err
+	}
+	if count != 0 && len(records) > count {
+		records = records[len(records)-count:]
+	}
+	for _, hr := range records {
+		items = append(items, &cio.HistoryItem{Time: hr.Time, OpCall: hr.OpCall, User: hr.User, Command: hr.Command})
+	}
+	cio.HistoryTable(items)
+	return nil
+}
+
+// readHistory returns the shell history records for the incident directory,
+// in the order they were written.  If mine is true, only those for the current
+// user are returned.
+func readHistory(mine bool) (records []*historyRecord, err error) {
+	var (
+		fh *os.File
+		me = historyUser()
+	)
+	if fh, err = os.Open(historyFile); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read %s: %s", historyFile, err)
+	}
+	defer fh.Close()
+	scan := bufio.NewScanner(fh)
+	for scan.Scan() {
+		var hr historyRecord
+
+		if json.Unmarshal(scan.Bytes(), &hr) == nil && (!mine || hr.User == me) {
+			records = append(records, &hr)
+		}
+	}
+	return records, nil
+}
+
+// loadHistory loads the commands from the history file into the shell command
+// line editor, for recall.
+func loadHistory() {
+	var commands []string
+
+	records, _ := readHistory(os.Getenv("PACKET_HISTORY") == "user")
+	if len(records) > historyRecallLimit {
+		records = records[len(records)-historyRecallLimit:]
+	}
+	for _, hr := range records {
+		commands = append(commands, hr.Command)
+	}
+	cio.SetHistory(commands)
+}
+
+// recordHistory appends a command to the history file.  args is the parsed
+// form of the command.  Commands that set hidden configuration settings (such
+// as the BBS password) are not recorded.
+func recordHistory(command string, args []string) {
+	var hr = historyRecord{Time: time.Now(), OpCall: config.C.OpCall, User: historyUser(), Command: command}
+
+	if setsHiddenField(args) {
+		return
+	}
+	// The history is shared by all operators using the incident directory,
+	// so it gets the same permissions as the other incident files.
+	fh, err := os.OpenFile(historyFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	if err != nil {
+		cio.Error("recording history: %s", err)
+		return
+	}
+	by, _ := json.Marshal(&hr)
+	_, err = fh.Write(append(by, '\n'))
+	if cerr := fh.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		cio.Error("recording history: %s", err)
+	}
+}
+
+// setsHiddenField returns whether the command is a "set config" command that
+// gives a new value for a hidden configuration setting.
+func setsHiddenField(args []string) bool {
+	var (
+		words  []string
+		fields []*message.Field
+	)
+
+	if args[0] != "set" {
+		return false
+	}
+	for _, arg := range args[1:] {
+		if !strings.HasPrefix(arg, "-") {
+			words = append(words, arg)
+		}
+	}
+	if len(words) < 3 || !strings.HasPrefix("config", words[0]) {
+		return false
+	}
+	// Resolve the field name the same way the "set" command does.
+	for _, f := range config.C.Fields {
+		if f.EditHelp != "" {
+			fields = append(fields, f)
+		}
+	}
+	field, err := expandFieldName(fields, words[1], true)
+	return err == nil && field.HideValue
+}
+
+// historyUser returns the name of the current login user.
+func historyUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/rothskeller/packet/envelope"
+	"github.com/rothskeller/packet/message"
+)
+
+// hookHomeDir is the directory, in the user's HOME, containing hooks that apply
+// to all incidents.  (Hooks in the incident directory take precedence.)
+const hookHomeDir = ".packet-hooks"
+
+// Hook events.  The hook for each event is an executable named "hook-«event»",
+// optionally with an extension.
+const (
+	hookReceived          = "received"           // private message received
+	hookReceivedImmediate = "received-immediate" // immediate private message received
+	hookReceipt           = "receipt"            // delivery receipt received
+	hookSent              = "sent"               // message sent
+	hookConnectFailed     = "connect-failed"     // couldn't connect to any BBS
+	hookBulletin          = "bulletin"           // bulletin received
+)
+
+// hookInfo is the information passed to a hook in its environment.  Fields
+// that don't apply to the event are left empty.
+type hookInfo struct {
+	LMI      string
+	Handling string
+	Type     string
+	Subject  string
+	File     string
+	BBS      string
+	Area     string
+	Error    string
+}
+
+// messageHookInfo returns the hook information for a message.
+func messageHookInfo(lmi string, env *envelope.Envelope, msg message.Message) (hi hookInfo) {
+	hi.LMI = lmi
+	_, _, hi.Handling, _, _ = message.DecodeSubject(env.SubjectLine)
+	if msg != nil {
+		hi.Type = msg.Base().Type.Tag
+	}
+	hi.Subject = env.SubjectLine
+	if abs, err := filepath.Abs(lmi + ".txt"); err == nil {
+		hi.File = abs
+	}
+	hi.BBS, hi.Area = env.ReceivedBBS, env.ReceivedArea
+	return hi
+}
+
+// runHook runs the hook for the specified event, if there is one.  It doesn't
+// wait for the hook to finish.  The hook's output is discarded; failure to
+// start it is noted in the log.
+func runHook(event string, hi hookInfo) {
+	var path = findHook(".", event)
+
+	if path == "" {
+		if home, err := os.UserHomeDir(); err == nil && home != "" {
+			path = findHook(filepath.Join(home, hookHomeDir), event)
+		}
+	}
+	if path == "" {
+		return
+	}
+	cmd := exec.Command(path)
+	cmd.Env = append(os.Environ(),
+		"PACKET_EVENT="+event,
+		"PACKET_LMI="+hi.LMI,
+		"PACKET_HANDLING="+hi.Handling,
+		"PACKET_TYPE="+hi.Type,
+		"PACKET_SUBJECT="+hi.Subject,
+		"PACKET_FILE="+hi.File,
+		"PACKET_BBS="+hi.BBS,
+		"PACKET_AREA="+hi.Area,
+		"PACKET_ERROR="+hi.Error,
+	)
+	if err := cmd.Start(); err != nil {
+		if logfile, err2 := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666); err2 == nil {
+			fmt.Fprintf(logfile, "[hook %s: %s]\n", path, err)
+			logfile.Close()
+		}
+		return
+	}
+	go cmd.Wait()
+}
+
+// findHook returns the path of the hook for the specified event in the
+// specified directory, or an empty string if there is none.
+func findHook(dir, event string) string {
+	var base = "hook-" + event
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		name := entry.Name()
+		if name != base && !strings.HasPrefix(name, base+".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		if runtime.GOOS != "windows" && info.Mode().Perm()&0111 == 0 {
+			continue // not executable
+		}
+		if abs, err := filepath.Abs(filepath.Join(dir, name)); err == nil {
+			return abs
+		}
+	}
+	return ""
+}
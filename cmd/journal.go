@@ -0,0 +1,402 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/mail"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet/envelope"
+	"github.com/rothskeller/packet/incident"
+	"github.com/rothskeller/packet/jnos"
+	"github.com/rothskeller/packet/xscmsg/delivrcpt"
+)
+
+// journalFile is the name of the file, in the incident directory, that records
+// BBS operations that are in progress.  It is removed at the end of each
+// successful connection, so if it exists at the start of a connection, the
+// previous connection was interrupted.
+const journalFile = "packet.journal"
+
+// Journal operations.
+const (
+	journalSendStart = "send-start" // about to send a message or receipt
+	journalSendAck   = "send-ack"   // BBS accepted a message
+	journalReceived  = "received"   // received a message and saved it
+	journalDRSent    = "dr-sent"    // BBS accepted a delivery receipt
+	journalKilled    = "killed"     // killed a received message from the BBS
+)
+
+// A journalEntry is a single entry in the journal.  LMI is the local message ID
+// of the message being operated on; for delivery receipts we send, it has a
+// ".DR" suffix.
+type journalEntry struct {
+	Time    time.Time
+	Op      string
+	LMI     string
+	BBS     string `json:",omitempty"`
+	Subject string `json:",omitempty"`
+	Area    string `json:",omitempty"`
+	NeedDR  bool   `json:",omitempty"`
+}
+
+// A journal is the open journal file.
+type journal struct {
+	fh *os.File
+}
+
+// openJournal opens the journal file, returning the entries already in it (if
+// any).
+func openJournal() (j *journal, entries []*journalEntry, err error) {
+	if fh, err := os.Open(journalFile); err == nil {
+		scan := bufio.NewScanner(fh)
+		for scan.Scan() {
+			var je journalEntry
+
+			// A partial last line, from a crash in the middle of
+			// writing it, is ignored.
+			if json.Unmarshal(scan.Bytes(), &je) == nil {
+				entries = append(entries, &je)
+			}
+		}
+		fh.Close()
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, nil, fmt.Errorf("read %s: %s", journalFile, err)
+	}
+	j = new(journal)
+	if j.fh, err = os.OpenFile(journalFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666); err != nil {
+		return nil, nil, fmt.Errorf("open %s: %s", journalFile, err)
+	}
+	return j, entries, nil
+}
+
+// record adds an entry to the journal, and makes sure it's on disk before
+// returning.
+func (j *journal) record(je journalEntry) (err error) {
+	var by []byte
+
+	if je.Time.IsZero() {
+		je.Time = time.Now()
+	}
+	by, _ = json.Marshal(&je)
+	by = append(by, '\n')
+	if _, err = j.fh.Write(by); err == nil {
+		err = j.fh.Sync()
+	}
+	if err != nil {
+		return fmt.Errorf("write %s: %s", journalFile, err)
+	}
+	return nil
+}
+
+// rewrite replaces the contents of the journal with the specified entries.
+func (j *journal) rewrite(entries []*journalEntry) (err error) {
+	if err = j.fh.Truncate(0); err != nil {
+		return fmt.Errorf("write %s: %s", journalFile, err)
+	}
+	for _, je := range entries {
+		if err = j.record(*je); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// close closes the journal.  If remove is true, the journal file is removed,
+// since all of the operations in it are complete.
+func (j *journal) close(remove bool) {
+	j.fh.Close()
+	if remove {
+		os.Remove(journalFile)
+	}
+}
+
+// journalState is the state of the operations on a single message, according
+// to the journal.
+type journalState struct {
+	sendStarted bool
+	sendAcked   bool
+	received    *journalEntry
+	drStarted   bool
+	drSent      bool
+	killed      bool
+}
+
+// reconcile examines the journal entries left over from an interrupted
+// connection, and finishes or resolves the operations that were in progress.
+// It reports what it recovered.
+func (c *connection) reconcile(entries []*journalEntry) (err error) {
+	var (
+		lmis    []string
+		states  = make(map[string]*journalState)
+		list    map[string][]int
+		pending []*journalEntry
+	)
+	if len(entries) == 0 {
+		return nil
+	}
+	// Replay the journal to get the last known state of each message.
+	for _, je := range entries {
+		if lmi, isRR := strings.CutSuffix(je.LMI, ".RR"); isRR {
+			if je.Op == journalSendAck {
+				if err = c.reconcileRR(lmi); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		lmi, isDR := strings.CutSuffix(je.LMI, ".DR")
+		st := states[lmi]
+		if st == nil {
+			st = new(journalState)
+			states[lmi] = st
+			lmis = append(lmis, lmi)
+		}
+		switch {
+		case je.Op == journalSendStart && isDR:
+			st.drStarted = true
+		case je.Op == journalSendStart:
+			st.sendStarted, st.sendAcked = true, false
+		case je.Op == journalSendAck:
+			st.sendAcked = true
+		case je.Op == journalReceived:
+			st.received, st.killed = je, false
+		case je.Op == journalDRSent:
+			st.drSent = true
+		case je.Op == journalKilled:
+			st.killed = true
+		}
+	}
+	cio.Status("Reconciling interrupted connection...")
+	for _, lmi := range lmis {
+		st := states[lmi]
+		if st.sendStarted {
+			if err = c.reconcileSend(lmi, st.sendAcked); err != nil {
+				return err
+			}
+		}
+		if st.received == nil {
+			continue
+		}
+		if st.received.NeedDR {
+			if err = c.reconcileDR(lmi, st.drStarted, st.drSent); err != nil {
+				return err
+			}
+		}
+		if st.received.Area != "" || st.killed {
+			continue
+		}
+		if st.received.BBS != "" && !strings.EqualFold(st.received.BBS, c.bbs.BBS) {
+			// It's on a different BBS than the one we're
+			// connected to.  Keep it for a later connection.
+			pending = append(pending, resolvedDR(st.received))
+			continue
+		}
+		// The message was received but not killed.  If it's still on
+		// the BBS, it needs to be killed without being received again.
+		if list == nil {
+			if list, err = c.listSubjects(); err != nil {
+				return err
+			}
+		}
+		// The list shows only a prefix of the subject, so other
+		// messages may share it; find the one that is really ours.
+		var matches []int
+		if matches, err = c.matchReceived(lmi, list[listSubject(st.received.Subject)]); err != nil {
+			return err
+		}
+		if len(matches) > 1 {
+			cio.Confirm("NOTE: %s was received during an interrupted connection, but %d messages on the BBS match it; leaving them there", lmi, len(matches))
+			continue
+		}
+		for _, msgnum := range matches {
+			cio.Confirm("NOTE: %s was received during an interrupted connection; removing it from the BBS", lmi)
+			if c.rcvlevel == 1 {
+				// Kill it when we get to it in the message
+				// sequence, so that the sequence isn't broken.
+				c.stale[msgnum] = lmi
+				pending = append(pending, resolvedDR(st.received))
+			} else {
+				if err = c.conn.Kill(msgnum); err != nil {
+					return fmt.Errorf("JNOS kill %d: %s", msgnum, err)
+				}
+				if err = c.journal.record(journalEntry{Op: journalKilled, LMI: lmi}); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	// Everything in the old journal has been resolved, except any kills
+	// we deferred.
+	return c.journal.rewrite(pending)
+}
+
+// resolvedDR returns a copy of a "received" journal entry, marked as no longer
+// needing a delivery receipt, since that has been resolved already.
+func resolvedDR(je *journalEntry) *journalEntry {
+	var nje = *je
+
+	nje.NeedDR = false
+	return &nje
+}
+
+// reconcileSend resolves a send that was in progress when a connection was
+// interrupted.  If the BBS accepted the message, it is marked sent.  If we
+// don't know whether the BBS accepted it, it is removed from the send queue so
+// that it won't be sent twice; the operator can queue it again if needed.
+func (c *connection) reconcileSend(lmi string, acked bool) (err error) {
+	env, msg, err := incident.ReadMessage(lmi)
+	if err != nil {
+		return fmt.Errorf("read %s: %s", lmi, err)
+	}
+	if env.IsFinal() {
+		return nil // save completed; nothing to do
+	}
+	c.tosend = slices.DeleteFunc(c.tosend, func(s string) bool { return s == lmi })
+	if acked {
+		stampMessage(env, msg, c.bbs.BBS)
+		if err = incident.SaveMessage(lmi, "", env, msg, false, false); err != nil {
+			return fmt.Errorf("save message %s: %s", lmi, err)
+		}
+		c.subjectToLMI[env.SubjectLine] = lmi
+		noteUnqueued(lmi)
+		cio.Confirm("NOTE: %s was sent during an interrupted connection; marked it sent", lmi)
+		return nil
+	}
+	env.ReadyToSend = false
+	if err = incident.SaveMessage(lmi, "", env, msg, false, false); err != nil {
+		return fmt.Errorf("save message %s: %s", lmi, err)
+	}
+	noteUnqueued(lmi)
+	cio.Confirm(`NOTE: %s may have been sent during an interrupted connection; removed it from the send queue.  Use "packet queue %s" to send it again.`, lmi, lmi)
+	return nil
+}
+
+// reconcileDR resolves the delivery receipt for a message received during an
+// interrupted connection.  If the receipt was never sent, it is sent now.  If
+// it was sent but not saved, it is saved.  If we don't know whether the BBS
+// accepted it, it is not sent again.
+func (c *connection) reconcileDR(lmi string, started, sent bool) (err error) {
+	if sent {
+		if _, err := os.Stat(lmi + ".DR0.txt"); err == nil {
+			return nil // save completed; nothing to do
+		}
+	} else if started {
+		cio.Confirm("NOTE: delivery receipt for %s may have been sent during an interrupted connection; not sending it again", lmi)
+		return nil
+	}
+	env, _, err := incident.ReadMessage(lmi)
+	if err != nil {
+		return fmt.Errorf("read %s: %s", lmi, err)
+	}
+	dr := delivrcpt.New()
+	dr.LocalMessageID = lmi
+	dr.DeliveredTime = env.ReceivedDate.Format("01/02/2006 15:04")
+	dr.MessageTo = env.To
+	dr.MessageSubject = env.SubjectLine
+	denv := &envelope.Envelope{To: env.From, SubjectLine: "DELIVERED: " + env.SubjectLine}
+	if sent {
+		stampMessage(denv, dr, c.bbs.BBS)
+		if err = incident.SaveReceipt(lmi, denv, dr); err != nil {
+			return fmt.Errorf("save receipt %s.DR: %s", lmi, err)
+		}
+		return nil
+	}
+	cio.Confirm("NOTE: %s was received during an interrupted connection; sending its delivery receipt", lmi)
+	return c.sendMessage(lmi+".DR", denv, dr)
+}
+
+// matchReceived returns those of the specified message numbers in the current
+// mailbox that hold the same message as the already received message lmi,
+// judged by its full subject line, sender, and date.
+func (c *connection) matchReceived(lmi string, msgnums []int) (matches []int, err error) {
+	if len(msgnums) == 0 {
+		return nil, nil
+	}
+	env, _, err := incident.ReadMessage(lmi)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %s", lmi, err)
+	}
+	for _, msgnum := range msgnums {
+		cio.Status("Reading message %d...", msgnum)
+		raw, err := c.conn.Read(msgnum)
+		if err != nil {
+			return nil, fmt.Errorf("JNOS read %d: %s", msgnum, err)
+		}
+		if sameMessage(raw, env) {
+			matches = append(matches, msgnum)
+		}
+	}
+	return matches, nil
+}
+
+// sameMessage returns whether the headers of the raw message, as read from
+// the BBS, identify it as the message with the specified envelope.
+func sameMessage(raw string, env *envelope.Envelope) bool {
+	var from, date, subject string
+
+	for _, line := range strings.Split(raw, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue // e.g. a "Message #1" line from JNOS
+		}
+		switch value = strings.TrimSpace(value); strings.ToLower(name) {
+		case "from":
+			from = value
+		case "date":
+			date = value
+		case "subject":
+			subject = value
+		}
+	}
+	if subject != env.SubjectLine {
+		return false
+	}
+	if a, err := mail.ParseAddress(from); err == nil {
+		from = a.Address
+	}
+	if a, err := mail.ParseAddress(env.From); err == nil && !strings.EqualFold(a.Address, from) {
+		return false
+	}
+	if d, err := mail.ParseDate(date); err == nil && !env.Date.IsZero() && !d.Equal(env.Date) {
+		return false
+	}
+	return true
+}
+
+// listSubjects returns a map from subject line (as shown in a JNOS list) to
+// message numbers, for the messages in the current mailbox.
+func (c *connection) listSubjects() (subjects map[string][]int, err error) {
+	var list *jnos.MessageList
+
+	cio.Status("Getting list of messages in inbox...")
+	if list, err = c.conn.List(""); err != nil {
+		return nil, fmt.Errorf("JNOS list: %s", err)
+	}
+	subjects = make(map[string][]int)
+	if list != nil {
+		for _, li := range list.Messages {
+			subjects[li.SubjectPrefix] = append(subjects[li.SubjectPrefix], li.Number)
+		}
+	}
+	return subjects, nil
+}
+
+// listSubject returns the form of the subject line that JNOS shows in message
+// lists:  truncated to 35 characters and trimmed.
+func listSubject(subject string) string {
+	if len(subject) > 35 {
+		subject = subject[:35]
+	}
+	return strings.TrimSpace(subject)
+}
@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rothskeller/packet/envelope"
+)
+
+func TestSameMessage(t *testing.T) {
+	var env = envelope.Envelope{
+		From:        "Test Operator <kc6aaa@w6xsc.ampr.org>",
+		SubjectLine: "AAA-101P_R_A subject line that is longer than the list shows",
+		Date:        time.Date(2026, 10, 12, 10, 0, 0, 0, time.FixedZone("PDT", -7*3600)),
+	}
+	tests := []struct {
+		name string
+		raw  string
+		want bool
+	}{
+		{"same", "Date: Mon, 12 Oct 2026 10:00:00 -0700\nFrom: kc6aaa@w6xsc.ampr.org\nTo: kc6bbb@w6xsc.ampr.org\nSubject: AAA-101P_R_A subject line that is longer than the list shows\n\nBody.\n", true},
+		{"JNOS header line", "Message #3 \r\nDate: Mon, 12 Oct 2026 17:00:00 +0000\r\nFrom: KC6AAA@w6xsc.ampr.org\r\nSubject: AAA-101P_R_A subject line that is longer than the list shows\r\n\r\nBody.\r\n", true},
+		{"same prefix", "Date: Mon, 12 Oct 2026 10:00:00 -0700\nFrom: kc6aaa@w6xsc.ampr.org\nSubject: AAA-101P_R_A subject line that is longer than usual\n\nBody.\n", false},
+		{"other sender", "Date: Mon, 12 Oct 2026 10:00:00 -0700\nFrom: kc6ccc@w6xsc.ampr.org\nSubject: AAA-101P_R_A subject line that is longer than the list shows\n\nBody.\n", false},
+		{"resent later", "Date: Mon, 12 Oct 2026 11:30:00 -0700\nFrom: kc6aaa@w6xsc.ampr.org\nSubject: AAA-101P_R_A subject line that is longer than the list shows\n\nBody.\n", false},
+		{"subject in body", "Date: Mon, 12 Oct 2026 10:00:00 -0700\nFrom: kc6aaa@w6xsc.ampr.org\n\nSubject: AAA-101P_R_A subject line that is longer than the list shows\n", false},
+	}
+	for _, tt := range tests {
+		if got := sameMessage(tt.raw, &env); got != tt.want {
+			t.Errorf("%s: sameMessage = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
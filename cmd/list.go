@@ -2,7 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/rothskeller/packet-shell/cio"
 	"github.com/rothskeller/packet-shell/config"
@@ -16,9 +19,25 @@ import (
 const (
 	listSlug = `List all messages in current directory`
 	listHelp = `
-usage: packet list
+usage: packet list [options]
+  -u, --unread           ⇥list unread received messages
+  -d, --drafts           ⇥list draft (unsent, unqueued) messages
+  -q, --queued           ⇥list messages queued for sending
+  -n, --no-receipt       ⇥list sent messages without delivery receipts
+  -r, --received         ⇥list received private messages
+  -b, --bulletins        ⇥list bulletins
+      --handling «hdl»   ⇥list messages with handling «hdl» (any of I, P, R)
+  -t, --type «tag»       ⇥list messages of type «tag» (e.g., ICS213)
+  -a, --area «area»      ⇥list bulletins retrieved from «area»
+  -s, --since «duration» ⇥list messages sent or received within «duration»
+      --to «address»     ⇥list messages addressed to «address»
+      --from «address»   ⇥list messages from «address»
+  -R, --reverse          ⇥list newest messages first
+  -l, --limit «count»    ⇥list only the «count» most recent messages
 
-The "list" (or "l") command lists stored messages.  Messages are listed in chronological order.  If standard output is a terminal, messages are listed in a table; otherwise, they are listed in CSV format.
+The "list" (or "l") command lists stored messages.  Messages are listed in chronological order, or in reverse chronological order with the --reverse (-R) flag.  If standard output is a terminal, messages are listed in a table; otherwise, they are listed in CSV format.
+
+By default, all messages are listed.  The options above restrict the list to matching messages.  The first six options select messages by status; if more than one of them is given, messages matching any of them are listed.  All other options must be met by every listed message.  The --type, --area, --to, and --from options can be repeated, or given comma-separated lists, to match any of several values.  An «area» or «address» without an "@" matches regardless of the distribution or host name.  The «duration» for --since is a number followed by "m" (minutes), "h" (hours), or "d" (days), e.g., "2h"; unsent messages have no time and are not listed when --since is given.  --limit is applied after all other options.
 
 The contents of the list vary based on the message type.  For received bulletins:
   TIME     ⇥is the time we retrieved it
@@ -47,7 +66,8 @@ Messages are color-coded in the list, when displayed on a capable terminal.  Bul
 Several markers can appear in the list for special cases.  These appear in the FLAGS column in CSV output, or in an otherwise unused column in terminal output.
   DRAFT   ⇥indicates an unsent message that is not queued for sending
   QUEUE   ⇥indicates an unsent message that is queued for sending
-  NO RCPT ⇥indicates a sent message for which no delivery receipt has been received
+  NO RCPT ⇥indicates a sent message for which no delivery receipt has been received (see "packet help overdue")
+  READ    ⇥indicates a sent message for which a read receipt has been received
   NEW     ⇥indicates a received message that has not been read
 
 The "connect" command will sometimes show sent messages with a destination message ID on a green background.  This is a transient indication that we just received a delivery receipt for the message.
@@ -55,9 +75,29 @@ The "connect" command will sometimes show sent messages with a destination messa
 )
 
 func cmdList(args []string) (err error) {
-	var lmis []string
-
+	var (
+		lf      listFilter
+		since   string
+		reverse bool
+		limit   int
+		lmis    []string
+		entries []*listEntry
+	)
 	flags := pflag.NewFlagSet("list", pflag.ContinueOnError)
+	flags.BoolVarP(&lf.unread, "unread", "u", false, "list unread received messages")
+	flags.BoolVarP(&lf.drafts, "drafts", "d", false, "list draft messages")
+	flags.BoolVarP(&lf.queued, "queued", "q", false, "list queued messages")
+	flags.BoolVarP(&lf.noReceipt, "no-receipt", "n", false, "list sent messages without delivery receipts")
+	flags.BoolVarP(&lf.received, "received", "r", false, "list received private messages")
+	flags.BoolVarP(&lf.bulletins, "bulletins", "b", false, "list bulletins")
+	flags.StringVar(&lf.handling, "handling", "", "list messages with the specified handling orders")
+	flags.StringSliceVarP(&lf.types, "type", "t", nil, "list messages of the specified type")
+	flags.StringSliceVarP(&lf.areas, "area", "a", nil, "list bulletins from the specified area")
+	flags.StringVarP(&since, "since", "s", "", "list messages sent or received within «duration»")
+	flags.StringSliceVar(&lf.to, "to", nil, "list messages to «address»")
+	flags.StringSliceVar(&lf.from, "from", nil, "list messages from «address»")
+	flags.BoolVarP(&reverse, "reverse", "R", false, "list newest messages first")
+	flags.IntVarP(&limit, "limit", "l", 0, "list only the «count» most recent messages")
 	flags.Usage = func() {} // we do our own
 	if err = flags.Parse(args); err == pflag.ErrHelp {
 		return cmdHelp([]string{"list"})
@@ -65,7 +105,22 @@ func cmdList(args []string) (err error) {
 		cio.Error("%s", err.Error())
 		return usage(listHelp)
 	}
-	if len(args) != 0 {
+	if flags.NArg() != 0 {
+		return usage(listHelp)
+	}
+	lf.handling = strings.ToUpper(lf.handling)
+	if strings.Trim(lf.handling, "IPR") != "" {
+		cio.Error("--handling must contain only I, P, and/or R")
+		return usage(listHelp)
+	}
+	if since != "" {
+		if lf.since, err = parseSince(since); err != nil {
+			cio.Error("invalid --since: %s", err)
+			return usage(listHelp)
+		}
+	}
+	if limit < 0 {
+		cio.Error("--limit must not be negative")
 		return usage(listHelp)
 	}
 	// Now read the list of files again and display those that should be
@@ -74,35 +129,194 @@ func cmdList(args []string) (err error) {
 		return fmt.Errorf("read list of messages: %s", err)
 	}
 	for _, lmi := range lmis {
-		env, _, err := incident.ReadMessage(lmi)
+		env, msg, err := incident.ReadMessage(lmi)
 		if err != nil {
 			continue
 		}
+		var le = listEntry{env: env, from: env.From}
+		_, _, le.handling, _, _ = message.DecodeSubject(env.SubjectLine)
+		if msg != nil {
+			le.tag = msg.Base().Type.Tag
+		}
 		if !env.IsReceived() {
 			if delivs, err := incident.Deliveries(lmi); err != nil {
 				return fmt.Errorf("%s: reading delivery receipts: %s", lmi, err)
 			} else {
+				var rrs []*readReceipt
+				if env.IsFinal() && !env.Bulletin {
+					rrs = readReceiptsFor(lmi)
+				}
 				toSave := env.To
 				for _, deliv := range delivs {
 					env.To = deliv.Recipient
 					li := listItemForMessage(lmi, deliv.RemoteMessageID, env)
-					if env.IsFinal() && deliv.RemoteMessageID == "" && !env.Bulletin {
+					if readBy(rrs, deliv.Recipient, len(delivs) == 1) {
+						li.Flag = "READ"
+					} else if env.IsFinal() && deliv.RemoteMessageID == "" && !env.Bulletin {
 						li.Flag = "NO RCPT"
 					}
-					cio.ListMessage(li)
+					dle := le
+					dle.li, dle.to = li, deliv.Recipient
+					entries = append(entries, &dle)
 				}
 				env.To = toSave
 			}
 		} else {
 			rmi, _, _, _, _ := message.DecodeSubject(env.SubjectLine)
-			li := listItemForMessage(lmi, rmi, env)
-			cio.ListMessage(li)
+			le.li, le.to = listItemForMessage(lmi, rmi, env), env.To
+			entries = append(entries, &le)
 		}
 	}
-	cio.EndMessageList("No messages.")
+	entries = slices.DeleteFunc(entries, func(le *listEntry) bool { return !lf.match(le) })
+	if limit != 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+	if reverse {
+		slices.Reverse(entries)
+	}
+	for _, le := range entries {
+		cio.ListMessage(le.li)
+	}
+	if lf.active() {
+		cio.EndMessageList("No matching messages.")
+	} else {
+		cio.EndMessageList("No messages.")
+	}
 	return nil
 }
 
+// A listEntry is a single line of the message list, along with the message
+// details needed to filter it.
+type listEntry struct {
+	li       *cio.ListItem
+	env      *envelope.Envelope
+	handling string
+	tag      string
+	to       string
+	from     string
+}
+
+// A listFilter describes which messages should be listed.  The status flags
+// (unread through bulletins) are alternatives:  a message is listed if it
+// matches any of them.  All other criteria must be met.
+type listFilter struct {
+	unread    bool
+	drafts    bool
+	queued    bool
+	noReceipt bool
+	received  bool
+	bulletins bool
+	handling  string
+	types     []string
+	areas     []string
+	since     time.Duration
+	to        []string
+	from      []string
+}
+
+// active returns whether any filter criteria have been specified.
+func (lf *listFilter) active() bool {
+	return lf.anyStatus() || lf.handling != "" || len(lf.types) != 0 || len(lf.areas) != 0 ||
+		lf.since != 0 || len(lf.to) != 0 || len(lf.from) != 0
+}
+
+// anyStatus returns whether any status filter flags have been specified.
+func (lf *listFilter) anyStatus() bool {
+	return lf.unread || lf.drafts || lf.queued || lf.noReceipt || lf.received || lf.bulletins
+}
+
+// match returns whether the list entry meets the filter criteria.
+func (lf *listFilter) match(le *listEntry) bool {
+	if lf.anyStatus() {
+		switch {
+		case lf.unread && le.li.Flag == "NEW":
+		case lf.drafts && le.li.Flag == "DRAFT":
+		case lf.queued && le.li.Flag == "QUEUE":
+		case lf.noReceipt && le.li.Flag == "NO RCPT":
+		case lf.received && le.env.IsReceived() && !le.env.Bulletin:
+		case lf.bulletins && le.env.Bulletin:
+		default:
+			return false
+		}
+	}
+	if lf.handling != "" && (le.handling == "" || !strings.Contains(lf.handling, le.handling)) {
+		return false
+	}
+	if len(lf.types) != 0 && !slices.ContainsFunc(lf.types, func(t string) bool {
+		return strings.EqualFold(t, le.tag)
+	}) {
+		return false
+	}
+	if len(lf.areas) != 0 && !slices.ContainsFunc(lf.areas, func(a string) bool {
+		return matchArea(a, le.env.ReceivedArea)
+	}) {
+		return false
+	}
+	if lf.since != 0 && (le.li.Time.IsZero() || time.Since(le.li.Time) > lf.since) {
+		return false
+	}
+	if len(lf.to) != 0 && !matchAddresses(lf.to, le.to) {
+		return false
+	}
+	if len(lf.from) != 0 && !matchAddresses(lf.from, le.from) {
+		return false
+	}
+	return true
+}
+
+// matchArea returns whether a bulletin area given on the command line matches
+// the area a bulletin was retrieved from.  The "@ALL" prefix of the
+// distribution is optional, and the distribution can be omitted entirely.
+func matchArea(want, area string) bool {
+	want = strings.Replace(strings.ToUpper(want), "@ALL", "@", 1)
+	area = strings.Replace(strings.ToUpper(area), "@ALL", "@", 1)
+	if area == "" {
+		return false
+	}
+	if !strings.Contains(want, "@") {
+		area, _, _ = strings.Cut(area, "@")
+	}
+	return want == area
+}
+
+// matchAddresses returns whether any of the addresses in the list matches any
+// of the wanted addresses.  A wanted address without a host name matches any
+// address with that mailbox name.
+func matchAddresses(wants []string, list string) bool {
+	addrs, err := envelope.ParseAddressList(list)
+	if err != nil {
+		return false
+	}
+	for _, addr := range addrs {
+		mailbox, _, _ := strings.Cut(addr.Address, "@")
+		for _, want := range wants {
+			if strings.EqualFold(want, addr.Address) ||
+				(!strings.Contains(want, "@") && strings.EqualFold(want, mailbox)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseSince parses the argument to --since:  a Go duration (e.g., "90m" or
+// "2h30m"), or a number of days (e.g., "3d").
+func parseSince(s string) (d time.Duration, err error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		var n int
+		if n, err = strconv.Atoi(days); err != nil {
+			return 0, fmt.Errorf("%q is not a valid duration", s)
+		}
+		d = time.Duration(n) * 24 * time.Hour
+	} else if d, err = time.ParseDuration(s); err != nil {
+		return 0, fmt.Errorf("%q is not a valid duration", s)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%q is not a positive duration", s)
+	}
+	return d, nil
+}
+
 func listItemForMessage(lmi, rmi string, env *envelope.Envelope) (li *cio.ListItem) {
 	li = new(cio.ListItem)
 	if env.Bulletin {
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/rothskeller/packet-shell/cio"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	logSlug = `List BBS connections or show a connection's transcript`
+	logHelp = `
+usage: packet log [«session»]
+
+The "log" command, without arguments, lists the BBS connection sessions made in the current incident directory.  For each session, it shows the session number, the start time, how long it lasted, the BBS and means of connection, the number of messages sent and received, the number of bytes of message content sent and received, and the error that ended the session, if any.  The list will be in human format if stdout is a terminal, and in CSV format otherwise.
+
+With a «session» number, the "log" command shows the transcript of that session, i.e., the conversation with the BBS.  «session» can also be "last" to show the transcript of the most recent session.
+
+The transcripts are taken from the "packet.log" file, which contains the raw transcript of every BBS connection.  When that file grows larger than one megabyte, it is renamed to "packet.log.1" at the start of the next connection (with any existing "packet.log.1" being renamed to "packet.log.2", and so on).  Only the five most recent of these old log files are kept.  Transcripts of sessions in older log files are no longer available, but the sessions are still listed.
+`
+)
+
+func cmdLog(args []string) (err error) {
+	var (
+		sessions []*sessionRecord
+		id       int
+		flags    = pflag.NewFlagSet("log", pflag.ContinueOnError)
+	)
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"log"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(logHelp)
+	}
+	args = flags.Args()
+	if len(args) > 1 {
+		return usage(logHelp)
+	}
+	if sessions, err = readSessions(); err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		var items = make([]*cio.SessionItem, len(sessions))
+
+		for i, sr := range sessions {
+			items[i] = &cio.SessionItem{
+				ID: sr.ID, Start: sr.Start, End: sr.End, BBS: sr.BBS, Transport: sr.Transport,
+				Sent: sr.Sent, Received: sr.Received, BytesSent: sr.BytesSent, BytesReceived: sr.BytesReceived,
+				Error: sr.Error,
+			}
+		}
+		cio.SessionTable(items)
+		return nil
+	}
+	if args[0] == "last" {
+		if len(sessions) == 0 {
+			return errors.New("no BBS connections have been made")
+		}
+		id = sessions[len(sessions)-1].ID
+	} else if id, err = strconv.Atoi(args[0]); err != nil || id < 1 {
+		return fmt.Errorf("%q is not a valid session number", args[0])
+	}
+	if transcript := sessionTranscript(id); transcript != "" {
+		os.Stdout.WriteString(transcript)
+		return nil
+	}
+	return fmt.Errorf("no transcript available for session %d", id)
+}
@@ -95,6 +95,16 @@ func cmdNew(args []string) (err error) {
 			}
 		}
 	}
+	if err = checkNewMessageID(nmid, newHelp); err != nil {
+		return err
+	}
+	return doNew(copyID, replyID, msg, nmid)
+}
+
+// checkNewMessageID verifies that the «new-message-id» given on the command
+// line, if any, can be used.  For an invalid one, it returns the usage error
+// for the supplied help text.
+func checkNewMessageID(nmid, helptext string) error {
 	switch {
 	case nmid == "":
 		if (!cio.InputIsTerm || !cio.OutputIsTerm) && config.C.TxMessageID == "" {
@@ -105,18 +115,17 @@ func cmdNew(args []string) (err error) {
 	default:
 		if n, err := strconv.Atoi(nmid); err != nil || n <= 0 {
 			cio.Error("%q is not a valid message number", nmid)
-			return usage(newHelp)
+			return usage(helptext)
 		}
 		if (!cio.InputIsTerm || !cio.OutputIsTerm) && config.C.TxMessageID == "" {
 			return errors.New("no message numbering pattern defined in configuration; must provide complete message ID")
 		}
 	}
-	return doNew(copyID, replyID, msg, nmid)
+	return nil
 }
 
 func doNew(copyID, replyID string, msg message.Message, nmid string) (err error) {
 	var (
-		lmi    string
 		srclmi string
 		env    *envelope.Envelope
 		srcmsg message.Message
@@ -206,6 +215,15 @@ func doNew(copyID, replyID string, msg message.Message, nmid string) (err error)
 			*msg.Base().FOpName = config.C.OpName
 		}
 	}
+	return finishNew(env, msg, nmid)
+}
+
+// finishNew assigns a local message ID to a newly created message, and then
+// either opens it for editing or saves it, depending on mode.  It is the
+// common code between new and resend.
+func finishNew(env *envelope.Envelope, msg message.Message, nmid string) (err error) {
+	var lmi string
+
 	if incident.MsgIDRE.MatchString(nmid) {
 		lmi = incident.UniqueMessageID(nmid)
 	} else if nmid != "" {
@@ -217,15 +235,20 @@ func doNew(copyID, replyID string, msg message.Message, nmid string) (err error)
 	} else if config.C.RxMessageID != "" {
 		lmi = incident.UniqueMessageID(config.C.RxMessageID)
 	}
-	if omi := msg.Base().FOriginMsgID; omi != nil {
+	omi := msg.Base().FOriginMsgID
+	if omi != nil {
 		*omi = lmi
 	}
 	if cio.InputIsTerm && cio.OutputIsTerm {
-		return doEdit("", env, msg, "", false)
+		if err = doEdit("", env, msg, "", false); err == nil && omi != nil {
+			scriptVars["LMI"] = *omi // may have been changed in the editor
+		}
+		return err
 	}
 	if err = incident.SaveMessage(lmi, "", env, msg, false, false); err != nil {
 		return fmt.Errorf("saving %s: %s", lmi, err)
 	}
+	scriptVars["LMI"] = lmi
 	fmt.Println(lmi)
 	return nil
 }
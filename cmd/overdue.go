@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet/incident"
+	"github.com/rothskeller/packet/message"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	overdueSlug = `List sent messages with overdue delivery receipts`
+	overdueHelp = `
+usage: packet overdue
+
+The "overdue" command lists sent messages for which delivery receipts are overdue.  Under the SCCo packet handling policy, immediate messages should be delivered right away, priority messages within one hour, and routine messages within two hours.  Any sent message that has gone longer than that without a delivery receipt from one or more of its recipients is listed, along with the time it was sent, how long it has been waiting, and the recipients who have not sent receipts.  Bulletins are not listed, since they don't get delivery receipts.
+
+The list will be in human format if stdout is a terminal, and in CSV format otherwise.  The "connect" command also gives a warning at the end of each connection if any delivery receipts are overdue.
+
+To send an overdue message again, to only those recipients who have not sent receipts, use the "resend" command.
+`
+)
+
+// overdueLimits gives the time within which a delivery receipt is expected,
+// for each handling order.
+var overdueLimits = map[string]time.Duration{
+	"I": 0,
+	"P": time.Hour,
+	"R": 2 * time.Hour,
+}
+
+func cmdOverdue(args []string) (err error) {
+	flags := pflag.NewFlagSet("overdue", pflag.ContinueOnError)
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"overdue"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(overdueHelp)
+	}
+	if flags.NArg() != 0 {
+		return usage(overdueHelp)
+	}
+	cio.OverdueTable(overdueMessages())
+	return nil
+}
+
+// overdueMessages returns the list of sent messages whose delivery receipts are
+// overdue.
+func overdueMessages() (items []*cio.OverdueItem) {
+	lmis, _ := incident.AllLMIs()
+	for _, lmi := range lmis {
+		env, _, err := incident.ReadMessage(lmi)
+		if err != nil || env.IsReceived() || !env.IsFinal() || env.Bulletin {
+			continue
+		}
+		_, _, handling, _, _ := message.DecodeSubject(env.SubjectLine)
+		limit, ok := overdueLimits[handling]
+		if !ok {
+			limit = overdueLimits["R"]
+		}
+		if time.Since(env.Date) <= limit {
+			continue
+		}
+		if missing := missingReceipts(lmi); len(missing) != 0 {
+			items = append(items, &cio.OverdueItem{
+				LMI:      lmi,
+				Handling: handling,
+				Sent:     env.Date,
+				To:       strings.Join(missing, ", "),
+				Subject:  env.SubjectLine,
+			})
+		}
+	}
+	return items
+}
+
+// missingReceipts returns the list of recipients of the specified sent message
+// that have not sent delivery receipts for it.
+func missingReceipts(lmi string) (missing []string) {
+	delivs, _ := incident.Deliveries(lmi)
+	for _, deliv := range delivs {
+		if deliv.RemoteMessageID == "" {
+			missing = append(missing, deliv.Recipient)
+		}
+	}
+	return missing
+}
+
+// warnOverdue gives a warning if any delivery receipts are overdue.
+func warnOverdue() {
+	if items := overdueMessages(); len(items) == 1 {
+		cio.Confirm(`WARNING: the delivery receipt for %s is overdue; run "packet overdue" for details`, items[0].LMI)
+	} else if len(items) > 1 {
+		cio.Confirm(`WARNING: delivery receipts for %d messages are overdue; run "packet overdue" for details`, len(items))
+	}
+}
@@ -8,7 +8,6 @@ import (
 	"runtime"
 
 	"github.com/rothskeller/packet-shell/cio"
-	"github.com/rothskeller/packet-shell/config"
 	"github.com/rothskeller/packet/envelope"
 	"github.com/rothskeller/packet/incident"
 	"github.com/rothskeller/packet/message"
@@ -83,9 +82,6 @@ func cmdPDF(args []string) (err error) {
 		return fmt.Errorf("starting PDF viewer: %s", err)
 	}
 	go func() { open.Wait() }()
-	if config.C.Unread[lmi] {
-		config.C.Unread[lmi] = false
-		config.SaveConfig()
-	}
+	markRead(lmi)
 	return nil
 }
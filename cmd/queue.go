@@ -1,8 +1,15 @@
 package cmd
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/rothskeller/packet-shell/cio"
 	"github.com/rothskeller/packet/envelope"
@@ -14,16 +21,25 @@ import (
 const (
 	queueSlug = `Add an unsent message to the send queue`
 	queueHelp = `
-usage: packet queue [--force] «message-id»
-  --force  queue a message with invalid contents
+usage: packet queue [flags] «message-id»
+       packet queue --order
+      --force  ⇥queue a message with invalid contents
+  -f, --front  ⇥move the message to the front of the send queue
+  -o, --order  ⇥list the send queue in the order it will be sent
 
 The "queue" command adds an unsent message to the send queue, if it is not already there.  The message will be sent during the next BBS connection.  «message-id» must be the local message ID of an unsent outgoing message.  It can be just the numeric part of the ID if that is unique.
+
+Queued messages are sent in order of handling:  immediate messages first, then priority, then routine.  Messages with the same handling are sent in the order they were queued.  With the --front (-f) flag, the message is moved to the front of the send queue, ahead of all others regardless of handling, and is queued if it wasn't already.  (If several messages are moved to the front, the one moved most recently is sent first.)
+
+With the --order (-o) flag, the command lists the messages in the send queue, in the order they will be sent.  The list will be in human format if stdout is a terminal, and in CSV format otherwise.
 `
 )
 
 func cmdQueue(args []string) (err error) {
 	var (
 		force bool
+		front bool
+		order bool
 		lmi   string
 		env   *envelope.Envelope
 		msg   message.Message
@@ -31,14 +47,28 @@ func cmdQueue(args []string) (err error) {
 		flags = pflag.NewFlagSet("queue", pflag.ContinueOnError)
 	)
 	flags.BoolVar(&force, "force", false, "queue a message with invalid contents")
+	flags.BoolVarP(&front, "front", "f", false, "move the message to the front of the send queue")
+	flags.BoolVarP(&order, "order", "o", false, "list the send queue in order")
 	flags.Usage = func() {} // we do our own
 	if err = flags.Parse(args); err == pflag.ErrHelp {
 		return cmdHelp([]string{"queue"})
 	} else if err != nil {
 		cio.Error("%s", err.Error())
 		return usage(queueHelp)
+	} else if err = gaveMutuallyExclusiveFlags(flags, "order", "force"); err != nil {
+		cio.Error("%s", err.Error())
+		return usage(queueHelp)
+	} else if err = gaveMutuallyExclusiveFlags(flags, "order", "front"); err != nil {
+		cio.Error("%s", err.Error())
+		return usage(queueHelp)
 	}
 	args = flags.Args()
+	if order {
+		if len(args) != 0 {
+			return usage(queueHelp)
+		}
+		return showQueueOrder()
+	}
 	if len(args) != 1 {
 		return usage(queueHelp)
 	}
@@ -60,17 +90,12 @@ func cmdQueue(args []string) (err error) {
 	}
 	if !env.ReadyToSend {
 		if !force {
-			var foundProblem bool
-
-			for _, f := range msg.Base().Fields {
-				if f.EditHelp != "" { // only check editable fields
-					if problem := f.EditValid(f); problem != "" {
-						cio.Error("%s", problem)
-						foundProblem = true
-					}
-				}
+			var problems = messageProblems(msg)
+
+			for _, problem := range problems {
+				cio.Error("%s", problem)
 			}
-			if foundProblem {
+			if len(problems) != 0 {
 				return errors.New("not queueing because message is invalid and --force was not used")
 			}
 		}
@@ -79,8 +104,162 @@ func cmdQueue(args []string) (err error) {
 			return fmt.Errorf("saving %s: %s", lmi, err)
 		}
 	}
+	if front {
+		noteFront(lmi)
+	} else {
+		noteQueued(lmi)
+	}
 	li = listItemForMessage(lmi, "", env)
 	li.NoHeader = true
 	cio.ListMessage(li)
 	return nil
 }
+
+// messageProblems returns the list of problems with the contents of a message,
+// if any.
+func messageProblems(msg message.Message) (problems []string) {
+	for _, f := range msg.Base().Fields {
+		if f.EditHelp != "" { // only check editable fields
+			if problem := f.EditValid(f); problem != "" {
+				problems = append(problems, problem)
+			}
+		}
+	}
+	return problems
+}
+
+// showQueueOrder lists the messages in the send queue, in the order they will
+// be sent.
+func showQueueOrder() error {
+	tosend, _, _ := preConnectScan(1, nil)
+	for _, lmi := range tosend {
+		if env, _, err := incident.ReadMessage(lmi); err == nil {
+			cio.ListMessage(listItemForMessage(lmi, "", env))
+		}
+	}
+	cio.EndMessageList("The send queue is empty.")
+	return nil
+}
+
+// A queueEntry records when a message was queued for sending, and when (if
+// ever) it was moved to the front of the send queue.  It is kept alongside the
+// message, in a file named with the message's LMI and a ".queue" extension.
+// (The message envelope itself has nowhere to put it:  a message with a Date
+// is a sent message.)
+type queueEntry struct {
+	Queued time.Time
+	Front  time.Time `json:",omitempty"`
+}
+
+// readQueueEntry returns the queue entry for a message, or nil if it has
+// none.
+func readQueueEntry(lmi string) (qe *queueEntry) {
+	by, err := os.ReadFile(lmi + ".queue")
+	if err != nil || json.Unmarshal(by, &qe) != nil {
+		return nil
+	}
+	return qe
+}
+
+// writeQueueEntry saves the queue entry for a message.
+func writeQueueEntry(lmi string, qe *queueEntry) {
+	by, _ := json.Marshal(qe)
+	if err := os.WriteFile(lmi+".queue", by, 0666); err != nil {
+		cio.Error("recording queue time: %s", err)
+	}
+}
+
+// noteQueued records the time that a message was queued for sending, unless
+// one is already recorded.
+func noteQueued(lmi string) {
+	if readQueueEntry(lmi) == nil {
+		writeQueueEntry(lmi, &queueEntry{Queued: time.Now()})
+	}
+}
+
+// noteFront records that a message has been moved to the front of the send
+// queue.
+func noteFront(lmi string) {
+	qe := readQueueEntry(lmi)
+	if qe == nil {
+		qe = &queueEntry{Queued: time.Now()}
+	}
+	qe.Front = time.Now()
+	writeQueueEntry(lmi, qe)
+}
+
+// noteUnqueued discards the recorded queue time for a message that has been
+// removed from the send queue (or sent).
+func noteUnqueued(lmi string) {
+	if err := os.Remove(lmi + ".queue"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		cio.Error("removing queue time: %s", err)
+	}
+}
+
+// noteRenamed moves the recorded queue time for a message whose LMI has
+// changed.
+func noteRenamed(oldlmi, newlmi string) {
+	if err := os.Rename(oldlmi+".queue", newlmi+".queue"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		cio.Error("moving queue time: %s", err)
+	}
+}
+
+// syncQueue returns the queue entries for the messages actually queued, which
+// are given as a map from LMI to handling.  Queued messages with no recorded
+// queue time (e.g., those queued by an older version of this program) are
+// given the modification time of their message file.  Recorded times for
+// messages that are no longer queued are discarded.
+func syncQueue(queued map[string]string) (entries map[string]*queueEntry) {
+	entries = make(map[string]*queueEntry, len(queued))
+	stale, _ := filepath.Glob("*.queue")
+	for _, fname := range stale {
+		lmi := strings.TrimSuffix(fname, ".queue")
+		if _, ok := queued[lmi]; !ok {
+			noteUnqueued(lmi)
+		}
+	}
+	for lmi := range queued {
+		if entries[lmi] = readQueueEntry(lmi); entries[lmi] != nil {
+			continue
+		}
+		entries[lmi] = &queueEntry{Queued: time.Now()}
+		if info, err := os.Stat(lmi + ".txt"); err == nil {
+			entries[lmi].Queued = info.ModTime()
+		}
+		writeQueueEntry(lmi, entries[lmi])
+	}
+	return entries
+}
+
+// sortSendQueue sorts the list of messages to be sent into the order they
+// should be sent:  messages moved to the front of the queue first (most
+// recently moved first), then by handling (immediate, priority, routine), and
+// then by the time they were queued.  handling is a map from LMI to handling
+// for all queued messages, and entries is the result of calling syncQueue
+// with it.
+func sortSendQueue(tosend []string, handling map[string]string, entries map[string]*queueEntry) {
+	slices.SortStableFunc(tosend, func(a, b string) int {
+		qa, qb := entries[a], entries[b]
+		if c := qb.Front.Compare(qa.Front); c != 0 {
+			return c
+		}
+		if c := handlingRank(handling[a]) - handlingRank(handling[b]); c != 0 {
+			return c
+		}
+		return qa.Queued.Compare(qb.Queued)
+	})
+}
+
+// handlingRank returns the send order rank for a handling order.
+func handlingRank(handling string) int {
+	switch handling {
+	case "I":
+		return 0
+	case "P":
+		return 1
+	case "R":
+		return 2
+	default:
+		return 3
+	}
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSendQueueOrder(t *testing.T) {
+	var (
+		base     = time.Date(2026, 10, 12, 10, 0, 0, 0, time.UTC)
+		handling = map[string]string{"AAA-101P": "R", "AAA-102P": "I", "AAA-103P": "R", "AAA-104P": "P", "AAA-105P": "R"}
+	)
+	if wd, err := os.Getwd(); err == nil {
+		t.Cleanup(func() { os.Chdir(wd) })
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	writeQueueEntry("AAA-101P", &queueEntry{Queued: base})
+	writeQueueEntry("AAA-102P", &queueEntry{Queued: base.Add(time.Minute)})
+	writeQueueEntry("AAA-103P", &queueEntry{Queued: base.Add(2 * time.Minute)})
+	writeQueueEntry("AAA-104P", &queueEntry{Queued: base.Add(3 * time.Minute)})
+	writeQueueEntry("AAA-999P", &queueEntry{Queued: base}) // no longer queued
+	// AAA-105P has no queue entry, so its message file's time is used.
+	if err := os.WriteFile("AAA-105P.txt", nil, 0666); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes("AAA-105P.txt", base.Add(-time.Minute), base.Add(-time.Minute))
+	noteFront("AAA-103P")
+	tosend := []string{"AAA-101P", "AAA-102P", "AAA-103P", "AAA-104P", "AAA-105P"}
+	sortSendQueue(tosend, handling, syncQueue(handling))
+	if want := []string{"AAA-103P", "AAA-102P", "AAA-104P", "AAA-105P", "AAA-101P"}; !slices.Equal(tosend, want) {
+		t.Errorf("send order %v, want %v", tosend, want)
+	}
+	if readQueueEntry("AAA-999P") != nil {
+		t.Error("AAA-999P queue entry not discarded")
+	}
+	if qe := readQueueEntry("AAA-105P"); qe == nil || !qe.Queued.Equal(base.Add(-time.Minute)) {
+		t.Errorf("AAA-105P queue entry %v, want queued at %s", qe, base.Add(-time.Minute))
+	}
+	noteRenamed("AAA-101P", "AAA-106P")
+	if readQueueEntry("AAA-101P") != nil || readQueueEntry("AAA-106P") == nil {
+		t.Error("queue entry not moved with renamed message")
+	}
+}
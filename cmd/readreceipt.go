@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet-shell/config"
+	"github.com/rothskeller/packet/envelope"
+	"github.com/rothskeller/packet/incident"
+	"github.com/rothskeller/packet/xscmsg/delivrcpt"
+	"github.com/rothskeller/packet/xscmsg/readrcpt"
+)
+
+// markRead marks a received message as having been read, which also
+// acknowledges it if it's an immediate message.  If it hadn't been read before,
+// and the configuration calls for it, a read receipt for it is queued to be
+// sent.
+func markRead(lmi string) {
+	cio.AcknowledgeAlerts(lmi)
+	if !config.C.Unread[lmi] {
+		return
+	}
+	config.C.Unread[lmi] = false
+	config.SaveConfig()
+	if err := queueReadReceipt(lmi); err != nil {
+		cio.Error("queueing read receipt for %s: %s", lmi, err)
+	}
+}
+
+// queueReadReceipt creates a read receipt for the specified received message,
+// and saves it in LMI.RR0.txt, queued to be sent during the next connection.
+// It does nothing if read receipts are turned off, or if the message is a
+// bulletin or a receipt.
+func queueReadReceipt(lmi string) (err error) {
+	if !config.C.SendReadReceipts {
+		return nil
+	}
+	env, msg, err := incident.ReadMessage(lmi)
+	if err != nil {
+		return err
+	}
+	if !env.IsReceived() || env.Bulletin {
+		return nil
+	}
+	switch msg.(type) {
+	case nil, *delivrcpt.DeliveryReceipt, *readrcpt.ReadReceipt:
+		return nil
+	}
+	if _, err = os.Stat(lmi + ".RR0.txt"); err == nil {
+		return nil // already have one
+	}
+	rr := readrcpt.New()
+	rr.MessageTo = env.To
+	rr.MessageSubject = env.SubjectLine
+	rr.ReadTime = time.Now().Format("01/02/2006 15:04")
+	renv := &envelope.Envelope{To: env.From, SubjectLine: "READ: " + env.SubjectLine, ReadyToSend: true}
+	if err = incident.SaveReceipt(lmi, renv, rr); err != nil {
+		return fmt.Errorf("save receipt %s.RR: %s", lmi, err)
+	}
+	return nil
+}
+
+// pendingReadReceipts returns the list of LMIs of received messages whose read
+// receipts are queued to be sent.
+func pendingReadReceipts() (lmis []string) {
+	files, _ := filepath.Glob("*.RR0.txt")
+	for _, file := range files {
+		// Read receipts for messages we sent are also named RR#, but
+		// they're received ones.
+		env, _, err := incident.ReadMessage(strings.TrimSuffix(file, ".txt"))
+		if err == nil && !env.IsReceived() && !env.IsFinal() && env.ReadyToSend {
+			lmis = append(lmis, strings.TrimSuffix(file, ".RR0.txt"))
+		}
+	}
+	return lmis
+}
+
+// reconcileRR resolves a read receipt that the BBS accepted during an
+// interrupted connection but that we never marked sent.  (A read receipt whose
+// acceptance is unknown is left queued, and so may be sent twice; that's
+// harmless.)
+func (c *connection) reconcileRR(lmi string) (err error) {
+	env, msg, err := incident.ReadMessage(lmi + ".RR0")
+	if err != nil {
+		return fmt.Errorf("read %s.RR0: %s", lmi, err)
+	}
+	c.readReceipts = slices.DeleteFunc(c.readReceipts, func(s string) bool { return s == lmi })
+	if env.IsFinal() {
+		return nil // save completed; nothing to do
+	}
+	stampMessage(env, msg, c.bbs.BBS)
+	env.ReadyToSend = false
+	if err = incident.SaveReceipt(lmi, env, msg); err != nil {
+		return fmt.Errorf("save receipt %s.RR: %s", lmi, err)
+	}
+	return nil
+}
+
+// A readReceipt is a read receipt that we received for one of our sent
+// messages.
+type readReceipt struct {
+	reader string // mailbox name of the station that read the message
+	time   string // time it was read, as reported in the receipt
+}
+
+// saveReadReceipt saves a read receipt received for the specified sent
+// message, in the first unused LMI.RR#.txt file.  If the same receipt was
+// already saved (e.g., during an interrupted connection), it isn't saved again.
+func saveReadReceipt(lmi, raw string) (err error) {
+	for n := 0; ; n++ {
+		filename := fmt.Sprintf("%s.RR%d.txt", lmi, n)
+		if existing, err := os.ReadFile(filename); err == nil {
+			if string(existing) == raw {
+				return nil
+			}
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return os.WriteFile(filename, []byte(raw), 0666)
+	}
+}
+
+// readReceiptsFor returns the read receipts we have received for the specified
+// sent message.
+func readReceiptsFor(lmi string) (rrs []*readReceipt) {
+	files, _ := filepath.Glob(lmi + ".RR*.txt")
+	for _, file := range files {
+		var rr readReceipt
+
+		env, msg, err := incident.ReadMessage(strings.TrimSuffix(file, ".txt"))
+		if err != nil {
+			continue
+		}
+		if addrs, err := envelope.ParseAddressList(env.From); err == nil && len(addrs) != 0 {
+			rr.reader = mailboxName(addrs[0].Address)
+		}
+		if m, ok := msg.(*readrcpt.ReadReceipt); ok && m.ReadTime != "" {
+			rr.time = m.ReadTime
+		} else {
+			rr.time = env.Date.Format("01/02/2006 15:04")
+		}
+		rrs = append(rrs, &rr)
+	}
+	return rrs
+}
+
+// readBy returns whether the specified recipient has read the message with the
+// specified read receipts.  If the message had only one recipient, any read
+// receipt counts.
+func readBy(rrs []*readReceipt, recipient string, only bool) bool {
+	if only {
+		return len(rrs) != 0
+	}
+	recipient = mailboxName(recipient)
+	for _, rr := range rrs {
+		if rr.reader == recipient {
+			return true
+		}
+	}
+	return false
+}
+
+// mailboxName returns the mailbox name part of an address, in upper case.
+func mailboxName(addr string) string {
+	mailbox, _, _ := strings.Cut(addr, "@")
+	return strings.ToUpper(mailbox)
+}
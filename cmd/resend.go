@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet/envelope"
+	"github.com/rothskeller/packet/incident"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	resendSlug = `Resend a message to recipients missing receipts`
+	resendHelp = `
+usage: packet resend «message-id» [«new-message-id»]
+
+The "resend" command creates a new outgoing message that is a copy of a sent message, addressed only to those recipients of the sent message that have not sent delivery receipts for it.  (See the "overdue" command for a list of messages with overdue receipts.)  If the message type has a "Reference" field, it is filled with the local message ID of the sent message.  The new message is handled just like one created with the "new" command:  in interactive (--no-script) mode, it is opened for editing; in --script mode, its local message ID is printed to standard output.  It is not queued for sending until that is requested.
+
+«message-id» must be the local message ID of a sent message.  It can be just the numeric part of the ID if that is unique.  «new-message-id» has the same meaning as for the "new" command (see "packet help new").
+`
+)
+
+func cmdResend(args []string) (err error) {
+	var nmid string
+
+	flags := pflag.NewFlagSet("resend", pflag.ContinueOnError)
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"resend"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(resendHelp)
+	}
+	args = flags.Args()
+	switch len(args) {
+	case 1:
+		// nothing
+	case 2:
+		nmid = args[1]
+	default:
+		return usage(resendHelp)
+	}
+	if err = checkNewMessageID(nmid, resendHelp); err != nil {
+		return err
+	}
+	srclmi, err := expandMessageID(args[0], false)
+	if err != nil {
+		return err
+	}
+	env, msg, err := incident.ReadMessage(srclmi)
+	if err != nil {
+		return fmt.Errorf("reading %s: %s", srclmi, err)
+	}
+	if env.IsReceived() || !env.IsFinal() {
+		return fmt.Errorf("%s is not a sent message", srclmi)
+	}
+	if env.Bulletin {
+		return errors.New("bulletins do not get delivery receipts")
+	}
+	if !msg.Editable() {
+		return fmt.Errorf("%ss do not support editing", msg.Base().Type.Tag)
+	}
+	missing := missingReceipts(srclmi)
+	if len(missing) == 0 {
+		return fmt.Errorf("all recipients of %s have sent delivery receipts", srclmi)
+	}
+	env = &envelope.Envelope{To: strings.Join(missing, ", "), SubjectLine: env.SubjectLine}
+	if msg.Base().FReference != nil {
+		*msg.Base().FReference = srclmi
+	}
+	cio.Confirm("Creating a new %s as a resend of %s to %s.", msg.Base().Type.Name, srclmi, env.To)
+	return finishNew(env, msg, nmid)
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet/incident"
+	"github.com/rothskeller/packet/xscmsg/checkin"
+	"github.com/rothskeller/packet/xscmsg/checkout"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	rosterSlug = `List stations checked into the net`
+	rosterHelp = `
+usage: packet roster [--on-net]
+  -o, --on-net  ⇥list only stations still on the net
+
+The "roster" command builds a net roster from the check-in and check-out messages received in the current incident.  For each station, it lists the operator call sign and name, the tactical call sign (if any), the time the station checked in, the time it checked out (if it has), and whether it is still on the net.  Stations are identified by their tactical call sign if they have one, and otherwise by their operator call sign.  A station that checks in again after checking out is back on the net.  The times are those given in the messages, i.e., when the stations sent them.
+
+With the --on-net (-o) flag, only stations still on the net are listed.
+
+The roster will be in human format if stdout is a terminal, and in CSV format otherwise.  The CSV format is suitable for import into a spreadsheet or a net-closing summary.
+`
+)
+
+func cmdRoster(args []string) (err error) {
+	var (
+		onNet bool
+		items []*cio.RosterItem
+		flags = pflag.NewFlagSet("roster", pflag.ContinueOnError)
+	)
+	flags.BoolVarP(&onNet, "on-net", "o", false, "list only stations still on the net")
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"roster"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(rosterHelp)
+	}
+	if flags.NArg() != 0 {
+		return usage(rosterHelp)
+	}
+	for _, ri := range buildRoster() {
+		if ri.OnNet || !onNet {
+			items = append(items, ri)
+		}
+	}
+	cio.RosterTable(items)
+	return nil
+}
+
+// A rosterEvent is a single received check-in or check-out message.
+type rosterEvent struct {
+	checkin bool
+	time    time.Time
+	opCall  string
+	opName  string
+	tacCall string
+	tacName string
+}
+
+// buildRoster returns the net roster, in order of check-in time.
+func buildRoster() (items []*cio.RosterItem) {
+	var (
+		events   []*rosterEvent
+		stations = make(map[string]*cio.RosterItem)
+	)
+	lmis, _ := incident.AllLMIs()
+	for _, lmi := range lmis {
+		env, msg, err := incident.ReadMessage(lmi)
+		if err != nil || !env.IsReceived() || msg == nil {
+			continue
+		}
+		var ev rosterEvent
+		switch msg.Base().Type.Tag {
+		case checkin.Type.Tag:
+			ev.checkin = true
+		case checkout.Type.Tag:
+			// nothing
+		default:
+			continue
+		}
+		if ev.time = env.Date; ev.time.IsZero() {
+			ev.time = env.ReceivedDate
+		}
+		mb := msg.Base()
+		ev.opCall, ev.opName = fieldValue(mb.FOpCall), fieldValue(mb.FOpName)
+		ev.tacCall, ev.tacName = fieldValue(mb.FTacCall), fieldValue(mb.FTacName)
+		events = append(events, &ev)
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].time.Before(events[j].time) })
+	for _, ev := range events {
+		var key = strings.ToUpper(ev.tacCall)
+
+		if key == "" {
+			key = strings.ToUpper(ev.opCall)
+		}
+		if key == "" {
+			continue
+		}
+		ri := stations[key]
+		if ri == nil {
+			ri = new(cio.RosterItem)
+			stations[key] = ri
+			items = append(items, ri)
+		}
+		// The latest message has the current operator and names.
+		ri.OpCall, ri.OpName = strings.ToUpper(ev.opCall), ev.opName
+		ri.TacCall, ri.TacName = strings.ToUpper(ev.tacCall), ev.tacName
+		if ev.checkin {
+			ri.CheckIn, ri.CheckOut, ri.OnNet = ev.time, time.Time{}, true
+		} else {
+			ri.CheckOut, ri.OnNet = ev.time, false
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return rosterSortTime(items[i]).Before(rosterSortTime(items[j]))
+	})
+	return items
+}
+
+// rosterSortTime returns the time by which a roster item is sorted:  its
+// check-in time, or its check-out time if it never checked in.
+func rosterSortTime(ri *cio.RosterItem) time.Time {
+	if !ri.CheckIn.IsZero() {
+		return ri.CheckIn
+	}
+	return ri.CheckOut
+}
+
+// fieldValue returns the value of a message field, or an empty string if the
+// message type doesn't have that field.
+func fieldValue(f *string) string {
+	if f == nil {
+		return ""
+	}
+	return strings.TrimSpace(*f)
+}
@@ -0,0 +1,173 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet/incident"
+	"github.com/rothskeller/packet/message"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	searchSlug = `Search message contents`
+	searchHelp = `
+usage: packet search [options] «pattern»
+  -i, --ignore-case    ⇥ignore upper/lower case differences
+  -E, --regexp         ⇥«pattern» is a regular expression
+  -f, --field «field»  ⇥search only the named field
+  -d, --dir «dir»      ⇥search the incident in «dir» (can be repeated)
+
+The "search" command searches the field values of all stored messages for «pattern», and lists the messages that contain it.  Each listed message is followed by the fields that matched, with a snippet of the matching text.  If standard output is a terminal, the results are listed in a table; otherwise, they are listed in CSV format, with one line per matching field.
+
+By default, «pattern» is plain text, and is matched exactly.  With the --regexp (-E) flag, it is a regular expression, in the syntax described at https://golang.org/s/re2syntax.  With the --ignore-case (-i) flag, upper and lower case letters are considered equal.  If «pattern» contains spaces, it need not be quoted.
+
+The --field (-f) flag restricts the search to a single field.  The «field» can be identified by its PackItForms tag or by its name, in the same manner as for the "show" and "set" commands.  Messages that have no such field are not searched.
+
+By default, the messages in the current directory are searched.  The --dir (-d) flag searches the messages in the named incident directory instead.  It can be repeated to search several directories; use "." for the current directory.
+`
+)
+
+func cmdSearch(args []string) (err error) {
+	var (
+		ignoreCase bool
+		isRegexp   bool
+		field      string
+		dirs       []string
+		pattern    string
+		re         *regexp.Regexp
+	)
+	flags := pflag.NewFlagSet("search", pflag.ContinueOnError)
+	flags.BoolVarP(&ignoreCase, "ignore-case", "i", false, "ignore upper/lower case differences")
+	flags.BoolVarP(&isRegexp, "regexp", "E", false, "pattern is a regular expression")
+	flags.StringVarP(&field, "field", "f", "", "search only the named field")
+	flags.StringArrayVarP(&dirs, "dir", "d", nil, "search the incident in the named directory")
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"search"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(searchHelp)
+	}
+	if flags.NArg() == 0 {
+		return usage(searchHelp)
+	}
+	pattern = strings.Join(flags.Args(), " ")
+	if !isRegexp {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	if re, err = regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid pattern: %s", err)
+	}
+	if len(dirs) == 0 {
+		searchIncident(re, field, "")
+	} else {
+		var cwd string
+
+		if cwd, err = os.Getwd(); err != nil {
+			return err
+		}
+		for _, dir := range dirs {
+			if err = os.Chdir(dir); err != nil {
+				cio.EndMessageList("")
+				return fmt.Errorf("%s: %s", dir, err)
+			}
+			searchIncident(re, field, dir)
+			if err = os.Chdir(cwd); err != nil {
+				cio.EndMessageList("")
+				return fmt.Errorf("returning to %s: %s", cwd, err)
+			}
+		}
+	}
+	cio.EndMessageList("No matching messages.")
+	return nil
+}
+
+// searchIncident searches the messages in the current directory for the
+// pattern, and lists those that match.  dir is the name of the directory for
+// display purposes, or an empty string if it's the shell's current directory.
+func searchIncident(re *regexp.Regexp, fieldName, dir string) {
+	lmis, err := incident.AllLMIs()
+	if err != nil {
+		cio.Error("%sread list of messages: %s", dirPrefix(dir), err)
+		return
+	}
+	for _, lmi := range lmis {
+		var (
+			fields  []*message.Field
+			matches []*cio.SearchMatch
+		)
+		env, msg, err := incident.ReadMessage(lmi)
+		if err != nil || msg == nil {
+			continue
+		}
+		if fieldName != "" {
+			f, err := expandFieldName(msg.Base().Fields, fieldName, cio.OutputIsTerm && cio.InputIsTerm)
+			if err != nil {
+				continue
+			}
+			fields = []*message.Field{f}
+		} else {
+			fields = msg.Base().Fields
+		}
+		for _, f := range fields {
+			if f.Value == nil || *f.Value == "" || f.HideValue {
+				continue
+			}
+			if loc := re.FindStringIndex(*f.Value); loc != nil {
+				matches = append(matches, &cio.SearchMatch{Field: f.Label, Snippet: searchSnippet(*f.Value, loc)})
+			}
+		}
+		if len(matches) == 0 {
+			continue
+		}
+		var rmi string
+		if env.IsReceived() {
+			rmi, _, _, _, _ = message.DecodeSubject(env.SubjectLine)
+		}
+		li := listItemForMessage(lmi, rmi, env)
+		if dir != "" && li.Flag == "NEW" {
+			// We don't have the unread list for other directories.
+			li.Flag = ""
+		}
+		cio.ListSearchResult(&cio.SearchResult{Item: li, Dir: dir, Matches: matches})
+	}
+}
+
+// searchSnippet returns the portion of the value surrounding the match at loc,
+// on a single line.
+func searchSnippet(value string, loc []int) (snippet string) {
+	var start, end = max(0, loc[0]-25), min(len(value), loc[1]+35)
+
+	// Don't split a multibyte character.
+	for start > 0 && !utf8.RuneStart(value[start]) {
+		start--
+	}
+	for end < len(value) && !utf8.RuneStart(value[end]) {
+		end++
+	}
+	snippet = strings.Join(strings.Fields(value[start:end]), " ")
+	if start > 0 {
+		snippet = "..." + snippet
+	}
+	if end < len(value) {
+		snippet += "..."
+	}
+	return snippet
+}
+
+// dirPrefix returns a prefix for error messages about the specified directory.
+func dirPrefix(dir string) string {
+	if dir == "" {
+		return ""
+	}
+	return dir + ": "
+}
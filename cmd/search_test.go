@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSearchSnippet(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		match string
+		want  string
+	}{
+		{"short", "Shelter open at the school", "open", "Shelter open at the school"},
+		{"long", strings.Repeat("a ", 30) + "NEEDLE" + strings.Repeat(" b", 30), "NEEDLE", "...a a a a a a a a a a a a NEEDLE b b b b b b b b b b b b b b b b b..."},
+		{"newlines", "Line one\nline two\n\nline three", "two", "Line one line two line three"},
+		// The bounds fall in the middle of the multibyte characters.
+		{"multibyte", strings.Repeat("é", 20) + "x NEEDLE yz" + strings.Repeat("日本", 20), "NEEDLE", "...ééééééééééééx NEEDLE yz日本日本日本日本日本日..."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idx := strings.Index(tt.value, tt.match)
+			got := searchSnippet(tt.value, []int{idx, idx + len(tt.match)})
+			if !utf8.ValidString(got) {
+				t.Errorf("searchSnippet = %q, not valid UTF-8", got)
+			}
+			if got != tt.want {
+				t.Errorf("searchSnippet = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
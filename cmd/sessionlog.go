@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// logFile is the name of the file, in the incident directory, that contains the
+// raw transcript of all BBS connections.
+const logFile = "packet.log"
+
+// sessionsFile is the name of the file, in the incident directory, that
+// contains a summary record of each BBS connection, one JSON object per line.
+const sessionsFile = "packet.sessions"
+
+// When the raw log file has grown past logRotateSize, it is renamed to
+// packet.log.1 at the start of the next connection (and any existing
+// packet.log.1 is renamed to packet.log.2, etc.).  Only logRotateKeep old log
+// files are kept.
+const (
+	logRotateSize = 1 << 20
+	logRotateKeep = 5
+)
+
+// A sessionRecord is the summary record of a single BBS connection.  The byte
+// counts are of message content (subject line and body) only; they don't
+// include the BBS dialog or the overhead of the transport.
+type sessionRecord struct {
+	ID            int
+	Start         time.Time
+	End           time.Time
+	BBS           string `json:",omitempty"`
+	Transport     string `json:",omitempty"`
+	Sent          int
+	Received      int
+	BytesSent     int
+	BytesReceived int
+	Error         string `json:",omitempty"`
+}
+
+// readSessions returns the session records, in the order they were written.
+func readSessions() (sessions []*sessionRecord, err error) {
+	var fh *os.File
+
+	if fh, err = os.Open(sessionsFile); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read %s: %s", sessionsFile, err)
+	}
+	defer fh.Close()
+	scan := bufio.NewScanner(fh)
+	for scan.Scan() {
+		var sr sessionRecord
+
+		if json.Unmarshal(scan.Bytes(), &sr) == nil {
+			sessions = append(sessions, &sr)
+		}
+	}
+	return sessions, nil
+}
+
+// startSession starts the session record for a new connection, and marks its
+// start in the raw log.
+func startSession(logfile *os.File) (sr *sessionRecord) {
+	sr = &sessionRecord{ID: 1, Start: time.Now()}
+	if sessions, _ := readSessions(); len(sessions) != 0 {
+		sr.ID = sessions[len(sessions)-1].ID + 1
+	}
+	fmt.Fprintf(logfile, "[session %d start %s]\n", sr.ID, sr.Start.Format(time.RFC3339))
+	return sr
+}
+
+// endSession finishes the session record for a connection, marks its end in
+// the raw log, and appends it to the sessions file.
+func (c *connection) endSession(sr *sessionRecord, logfile *os.File, err error) {
+	sr.End = time.Now()
+	if c.bbs != nil {
+		sr.BBS, sr.Transport = c.bbs.BBS, c.bbs.ConnType()
+	}
+	sr.Sent, sr.Received = c.sent, c.received
+	sr.BytesSent, sr.BytesReceived = c.bytesSent, c.bytesReceived
+	if err != nil {
+		sr.Error = err.Error()
+	}
+	fmt.Fprintf(logfile, "\n[session %d end %s: %d sent, %d received",
+		sr.ID, sr.End.Format(time.RFC3339), sr.Sent, sr.Received)
+	if sr.Error != "" {
+		fmt.Fprintf(logfile, "; %s", sr.Error)
+	}
+	fmt.Fprintln(logfile, "]")
+	if fh, err := os.OpenFile(sessionsFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666); err == nil {
+		by, _ := json.Marshal(sr)
+		fh.Write(append(by, '\n'))
+		fh.Close()
+	}
+}
+
+// sessionTranscript returns the transcript of the specified session, taken from
+// the raw log files.  It returns an empty string if the transcript is no longer
+// available (i.e., the log file containing it has been rotated away).
+func sessionTranscript(id int) (transcript string) {
+	var (
+		sb    strings.Builder
+		in    bool
+		start = fmt.Sprintf("[session %d start ", id)
+		end   = fmt.Sprintf("[session %d end ", id)
+	)
+	// Look through the log files from oldest to newest.
+	for i := logRotateKeep; i >= 0; i-- {
+		var name = logFile
+
+		if i > 0 {
+			name = fmt.Sprintf("%s.%d", logFile, i)
+		}
+		fh, err := os.Open(name)
+		if err != nil {
+			continue
+		}
+		scan := bufio.NewScanner(fh)
+		scan.Buffer(nil, 1<<20)
+		for scan.Scan() {
+			line := scan.Text()
+			if idx := strings.Index(line, start); idx >= 0 {
+				sb.Reset()
+				line, in = line[idx:], true
+			}
+			if in {
+				sb.WriteString(line)
+				sb.WriteByte('\n')
+				if strings.Contains(line, end) {
+					fh.Close()
+					return sb.String()
+				}
+			}
+		}
+		fh.Close()
+	}
+	// If we get here, the session never ended (e.g., the program crashed),
+	// so return whatever we have.
+	return sb.String()
+}
+
+// rotateLog rotates the raw log file if it has grown too large.
+func rotateLog() {
+	if info, err := os.Stat(logFile); err != nil || info.Size() < logRotateSize {
+		return
+	}
+	os.Remove(fmt.Sprintf("%s.%d", logFile, logRotateKeep))
+	for i := logRotateKeep - 1; i > 0; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", logFile, i), fmt.Sprintf("%s.%d", logFile, i+1))
+	}
+	os.Rename(logFile, logFile+".1")
+}
@@ -161,10 +161,18 @@ func cmdSet(args []string) (err error) {
 			return fmt.Errorf("saving %s: %s", lmichange, err)
 		}
 		incident.RemoveMessage(lmi)
+		if env.ReadyToSend {
+			noteRenamed(lmi, lmichange)
+		} else {
+			noteUnqueued(lmi)
+		}
 		return nil
 	}
 	if err = incident.SaveMessage(lmi, "", env, msg, fastsave, false); err != nil {
 		return fmt.Errorf("saving %s: %s", lmi, err)
 	}
+	if !env.ReadyToSend {
+		noteUnqueued(lmi)
+	}
 	return nil
 }
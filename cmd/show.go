@@ -22,6 +22,8 @@ The "show" (or "s") command displays a message in a two-column field-name / fiel
 «message-id» must be the local or remote message ID of the message to display.  It can be just the numeric part of the ID if that is unique.  If the word "config" (or an abbreviation) is used, the "show" command shows the incident / activation settings (see "packet help config").
 
 «field-name» is an optional name of a single field to display.  It can be the PackItForms tag for the field (including the trailing period, if any), or it can be the full field name.  When standard output is a terminal, it can be a shortened version of the field name, such as "ocs" for "Operator Call Sign."
+
+With JSON output (see "packet help script"), the message is shown as a single object containing the envelope data and a list of fields, each with its label, PackItForms tag, value, and validation problem (if any).  When «field-name» is given, the list contains only that field.
 `
 )
 
@@ -54,6 +56,11 @@ func cmdShow(args []string) (err error) {
 		if env, msg, err = incident.ReadMessage(lmi); err != nil {
 			return fmt.Errorf("reading %s: %s", lmi, err)
 		}
+	}
+	if cio.OutputJSON {
+		return showJSON(lmi, env, msg, args[1:])
+	}
+	if env != nil {
 		// Create artificial "fields" for the envelope data we want to
 		// show.
 		fields = append(fields, makeArtificialField("Message Type", strings.ToUpper(msg.Base().Type.Name[:1])+msg.Base().Type.Name[1:]))
@@ -71,6 +78,9 @@ func cmdShow(args []string) (err error) {
 			}
 			if !env.Date.IsZero() {
 				fields = append(fields, makeArtificialField("Sent", env.Date.Format("01/02/2006 15:04")))
+				for _, rr := range readReceiptsFor(lmi) {
+					fields = append(fields, makeArtificialField("Read", fmt.Sprintf("%s by %s", rr.time, rr.reader)))
+				}
 			}
 		}
 	}
@@ -108,9 +118,56 @@ func cmdShow(args []string) (err error) {
 		cio.ShowNameValue(f.Label, f.TableValue(f), labellen)
 	}
 	cio.EndNameValueList()
-	if config.C.Unread[lmi] {
-		config.C.Unread[lmi] = false
-		config.SaveConfig()
+	markRead(lmi)
+	return nil
+}
+
+// showJSON shows a message, or a single field of it, in JSON format.  Envelope
+// data are reported separately rather than as artificial fields.
+func showJSON(lmi string, env *envelope.Envelope, msg message.Message, fieldName []string) (err error) {
+	var (
+		md     = cio.MessageDetail{LMI: lmi, Type: msg.Base().Type.Tag, TypeName: msg.Base().Type.Name}
+		fields = msg.Base().Fields
+	)
+	if env != nil {
+		md.Bulletin, md.From, md.To, md.Subject = env.Bulletin, env.From, env.To, env.SubjectLine
+		md.Sent, md.ReceivedBBS, md.ReceivedArea = env.Date, env.ReceivedBBS, env.ReceivedArea
+		switch {
+		case env.IsReceived():
+			md.Status, md.Received = "received", env.ReceivedDate
+		case env.IsFinal():
+			md.Status = "sent"
+			for _, rr := range readReceiptsFor(lmi) {
+				md.ReadBy = append(md.ReadBy, &cio.ReadDetail{Reader: rr.reader, Time: rr.time})
+			}
+		case env.ReadyToSend:
+			md.Status = "queued"
+		default:
+			md.Status = "draft"
+		}
+	}
+	if len(fieldName) != 0 {
+		var field *message.Field
+
+		if field, err = expandFieldName(fields, fieldName[0], false); err != nil {
+			return err
+		}
+		fields = []*message.Field{field}
+	}
+	md.Fields = []*cio.FieldDetail{}
+	for _, f := range fields {
+		if f.Value == nil {
+			continue
+		}
+		fd := cio.FieldDetail{Label: f.Label, Tag: f.PIFOTag, Value: *f.Value}
+		if f.EditValid != nil {
+			fd.Problem = f.EditValid(f)
+		}
+		md.Fields = append(md.Fields, &fd)
+	}
+	cio.ShowMessageJSON(&md)
+	if len(fieldName) == 0 {
+		markRead(lmi)
 	}
 	return nil
 }
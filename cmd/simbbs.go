@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+
+	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet-shell/config"
+	"github.com/rothskeller/packet-shell/simbbs"
+	"github.com/spf13/pflag"
+)
+
+const (
+	simbbsSlug = `Run a simulated BBS for drills and testing`
+	simbbsHelp = `
+usage: packet simbbs [flags] «directory»
+  -c, --call «call»       ⇥call sign of the simulated BBS
+  -l, --listen «address»  ⇥network address to listen on (default 127.0.0.1)
+  -p, --port «port»       ⇥TCP port to listen on (default 2323)
+  -v, --verbose           ⇥show BBS conversations
+
+The "simbbs" command runs a simulated JNOS BBS, reachable over telnet, until it is interrupted with Ctrl-C.  It is useful for tabletop drills and for testing without a live BBS.  It supports the subset of JNOS commands that the "packet" command uses:  login, sending private messages and bulletins, reading, killing, and listing messages, and switching areas.
+
+The simulated BBS loads its mailboxes from the named «directory».  Each subdirectory of it is a mail area, named by the subdirectory name (e.g., "kc6rsc" for a user's mailbox, or "xscperm" for a bulletin area).  Each file in a subdirectory is an RFC-5322 (email format) message in that area.  Messages sent to the simulated BBS, and messages killed from it, are kept in memory only; the directory is not changed.  All private messages are delivered to local mailboxes, regardless of the BBS named in their addresses.  Any password is accepted at login.
+
+By default, the simulated BBS accepts connections only from the computer it is running on.  Since it accepts any password, it should not be reachable from other computers unless that is needed.  To run a drill with stations on other computers, use the --listen flag to give the network address to listen on (e.g., the computer's address on the drill network, or "0.0.0.0" for all networks).
+
+The --call flag gives the call sign of the simulated BBS.  It defaults to the BBS named in the current configuration.  To connect to the simulated BBS, set the "BBS Connection" configuration setting to "Internet", the "BBS Port Number" to the --port value, and the "BBS Hostname" to a name that starts with the BBS call sign and refers to the computer running the simulated BBS (e.g., "w6xsc.localhost", if needed added to the computer's hosts file).
+`
+)
+
+func cmdSimBBS(args []string) (err error) {
+	var (
+		call    string
+		listen  string
+		port    int
+		verbose bool
+		log     io.Writer
+		server  *simbbs.Server
+		l       net.Listener
+		flags   = pflag.NewFlagSet("simbbs", pflag.ContinueOnError)
+	)
+	flags.StringVarP(&call, "call", "c", config.C.BBS, "call sign of the simulated BBS")
+	flags.StringVarP(&listen, "listen", "l", "127.0.0.1", "network address to listen on")
+	flags.IntVarP(&port, "port", "p", 2323, "TCP port to listen on")
+	flags.BoolVarP(&verbose, "verbose", "v", false, "show BBS conversations")
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"simbbs"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(simbbsHelp)
+	}
+	if flags.NArg() != 1 {
+		return usage(simbbsHelp)
+	}
+	if call == "" {
+		return errors.New("no BBS call sign configured; use --call")
+	}
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%d is not a valid port number", port)
+	}
+	if verbose {
+		log = os.Stdout
+	}
+	if server, err = simbbs.New(call, flags.Arg(0), log); err != nil {
+		return fmt.Errorf("load mailboxes: %s", err)
+	}
+	if l, err = net.Listen("tcp", net.JoinHostPort(listen, strconv.Itoa(port))); err != nil {
+		return err
+	}
+	// Stop the server when interrupted.
+	sigintch := make(chan os.Signal, 1)
+	signal.Notify(sigintch, os.Interrupt)
+	defer func() {
+		signal.Stop(sigintch)
+		close(sigintch)
+	}()
+	go func() {
+		<-sigintch
+		l.Close()
+	}()
+	fmt.Printf("Simulated BBS %s listening on %s.  Press Ctrl-C to stop.\n", call, l.Addr())
+	return server.Serve(l)
+}
@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/rothskeller/packet-shell/cio"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	sourceSlug = `Run commands from a file`
+	sourceHelp = `
+usage: packet source «file»
+       packet -f «file»
+
+The "source" command runs the packet shell commands in «file», one per line, as if they had been typed into the shell.  All of the commands run in a single process, so they run much faster than a script that runs "packet" for each command.  «file» can be "-" to read commands from standard input.
+
+Blank lines, and lines whose first non-blank character is "#", are ignored.  Input and output redirection ("<", ">", and ">>") works as it does in the shell.
+
+Variables can be set with lines of the form ` + "`" + `«name»=«value»` + "`" + `, where «name» consists of letters, digits, and underscores.  A variable is used by writing $«name» or ${«name»} anywhere in a command line (or in the «value» of another variable); it is replaced by the variable's value.  If there is no such variable, the environment variable of that name is used, and if there is none of those either, it is an error.  Use $$ for a literal dollar sign.  Variables are not replaced inside single quotes, so a command like ` + "`" + `alias checkin='new ci; queue $LMI'` + "`" + ` defines the alias with the $LMI reference intact, to be replaced when the alias is run.  The variable $LMI is set to the local message ID of the message created by the most recent "new" or "resend" command, so that subsequent commands can refer to it:
+    new ICS213
+    set $LMI Subject Exercise traffic
+    queue $LMI
+
+By default, when a command fails, its error is reported (with the file name and line number) and the following commands are still run; "source" fails at the end if any command failed.  After the line "set -e", "source" stops at the first failing command.  "set +e" restores the default.  A "quit" command stops running commands from the file.
+
+Note that commands run from a file behave according to whether standard input and output are terminals, just like commands typed into the shell.  When running a command file from a terminal, use "packet --script -f «file»" to get script-friendly behavior (see "packet help script").
+`
+)
+
+// maxSourceDepth is the maximum nesting of "source" commands.
+const maxSourceDepth = 10
+
+var (
+	// scriptVars are the variables that can be used in sourced command
+	// files.  They persist for the life of the process.
+	scriptVars = make(map[string]string)
+	// sourceDepth is the nesting depth of "source" commands.
+	sourceDepth int
+)
+
+var (
+	assignmentRE = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)=(.*)$`)
+	variableRE   = regexp.MustCompile(`\$(?:\$|[A-Za-z_][A-Za-z0-9_]*|\{[A-Za-z_][A-Za-z0-9_]*\})`)
+)
+
+func cmdSource(args []string) (err error) {
+	var (
+		fh          *os.File
+		scan        *bufio.Scanner
+		lineno      int
+		stopOnError bool
+		failures    int
+	)
+	flags := pflag.NewFlagSet("source", pflag.ContinueOnError)
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"source"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(sourceHelp)
+	}
+	if flags.NArg() != 1 {
+		return usage(sourceHelp)
+	}
+	if sourceDepth >= maxSourceDepth {
+		return errors.New("command files nested too deeply")
+	}
+	filename := flags.Arg(0)
+	if filename == "-" {
+		fh = os.Stdin
+	} else if fh, err = os.Open(filename); err != nil {
+		return err
+	} else {
+		defer fh.Close()
+	}
+	sourceDepth++
+	defer func() { sourceDepth-- }()
+	scan = bufio.NewScanner(fh)
+	for scan.Scan() {
+		var (
+			line    = strings.TrimSpace(scan.Text())
+			cmdargs []string
+			in, out *os.File
+		)
+		lineno++
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		switch line {
+		case "set -e":
+			stopOnError = true
+			continue
+		case "set +e":
+			stopOnError = false
+			continue
+		}
+		if line, err = expandVariables(line); err == nil {
+			if match := assignmentRE.FindStringSubmatch(line); match != nil {
+				scriptVars[match[1]] = strings.TrimSpace(match[2])
+				continue
+			}
+			if cmdargs, in, out, err = parseCommandLine(line); err == nil {
+				if len(cmdargs) != 0 && cmdargs[0] == "packet" {
+					cmdargs = cmdargs[1:]
+				}
+				if len(cmdargs) != 0 {
+					err = runRedirected(cmdargs, in, out)
+				}
+			}
+		}
+		if err == ErrQuit {
+			return nil
+		}
+		if err != nil {
+			failures++
+			if stopOnError {
+				return fmt.Errorf("%s:%d: %s", filename, lineno, err)
+			}
+			cio.Error("%s:%d: %s", filename, lineno, err)
+		}
+	}
+	if err = scan.Err(); err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+	switch failures {
+	case 0:
+		return nil
+	case 1:
+		return fmt.Errorf("%s: 1 command failed", filename)
+	default:
+		return fmt.Errorf("%s: %d commands failed", filename, failures)
+	}
+}
+
+// expandVariables replaces the variable references in a line with their
+// values.  References inside single quotes are left alone.
+func expandVariables(line string) (_ string, err error) {
+	var (
+		sb      strings.Builder
+		dquoted bool
+	)
+	for line != "" {
+		var idx int
+
+		// Expand everything up to the next single quote that isn't
+		// inside double quotes.
+		for idx = 0; idx < len(line); idx++ {
+			if line[idx] == '"' {
+				dquoted = !dquoted
+			} else if line[idx] == '\'' && !dquoted {
+				break
+			}
+		}
+		sb.WriteString(expandReferences(line[:idx], &err))
+		line = line[idx:]
+		if line == "" {
+			break
+		}
+		// Copy the single-quoted string unchanged.
+		if idx = strings.IndexByte(line[1:], '\''); idx < 0 {
+			idx = len(line)
+		} else {
+			idx += 2
+		}
+		sb.WriteString(line[:idx])
+		line = line[idx:]
+	}
+	return sb.String(), err
+}
+
+// expandReferences replaces the variable references in s with their values.
+// If it finds an undefined variable and *errp is nil, it sets *errp.
+func expandReferences(s string, errp *error) string {
+	return variableRE.ReplaceAllStringFunc(s, func(ref string) string {
+		if ref == "$$" {
+			return "$"
+		}
+		name := strings.Trim(ref[1:], "{}")
+		if value, ok := scriptVars[name]; ok {
+			return value
+		}
+		if value, ok := os.LookupEnv(name); ok {
+			return value
+		}
+		if *errp == nil {
+			*errp = fmt.Errorf("undefined variable %s", ref)
+		}
+		return ref
+	})
+}
@@ -18,19 +18,34 @@ Tactical Call Sign
 Tactical Station Name
     These are the assigned call sign and name of the tactical station being operated.  They are filled into various forms.
 BBS Connection
-    This is the type of BBS connection to use:  Radio or Internet.
+    This is the type of BBS connection to use:  Radio (through a Kantronics KPC-3 Plus TNC), Radio (KISS) (through a KISS-mode TNC), Radio (KISS/TCP) (through a soundcard modem program such as Direwolf), Radio (AGWPE) (through a soundcard modem program with an AGWPE interface), or Internet.
 BBS Address
     Radio connections: This is the AX.25 address of the BBS (e.g. W6XSC-1).
 TNC Serial Port
-    Radio connections: This the serial port to use to connect to the TNC.
+    Radio and Radio (KISS) connections: This the serial port to use to connect to the TNC.
+TNC Baud Rate
+    Radio (KISS) connections: This is the speed of the serial port connection to the TNC (default 9600).
+TNC TXDELAY
+    Radio (KISS) connections: This is the transmitter keyup delay in milliseconds (default 300).
+TNC Persistence
+    Radio (KISS) connections: This is the channel access persistence parameter, 0-255 (default 63).
+TNC Hostname
+TNC Port Number
+    Radio (KISS/TCP) and Radio (AGWPE) connections: This is the hostname and port number of the KISS or AGWPE interface of the soundcard modem program (default localhost port 8001 for KISS, 8000 for AGWPE).
+AGW Radio Port
+    Radio (AGWPE) connections: This is the AGWPE radio port to use, numbered from zero (default 0).
 BBS Hostname
     Internet connections: This is the hostname of the BBS server.
 BBS Port
     Internet connections: This is the port number to connect to on the BBS.
 Password
     Internet connections: This is the password to use to log into the BBS.  The "packet" commands will log in using the "Tactical Call Sign" if given, otherwise the "Operator Call Sign".  Note that this password is stored in clear text in the "packet.conf" file; make sure to protect it properly.
+Backup BBSes
+    These are the backup BBS connections, tried in order when the BBS connection described above can't be reached.  They can't be edited directly; use the "packet bbs" command to manage them.
 Message Numbering
     This is the message number of the first message; subsequent messages will follow the same pattern with increasing sequence numbers.
+Send Read Receipts
+    If this is "Yes", a read receipt is sent for each received message (other than bulletins and receipts) after it is first viewed.  The read receipt is queued when the message is viewed, and sent during the next BBS connection.
 Default Destination
     This is the address list to be added to the "To" field of any new message.
 Default To ICS Position
@@ -60,17 +75,48 @@ Each incident (or other activation) has its own directory.  It contains the foll
   ics309.csv        ⇥ICS-309 communications log, in CSV format
   ics309.pdf        ⇥ICS-309 communications log, in PDF format
   packet.conf       ⇥incident/activation configuration settings, in JSON format
-  packet.log        ⇥text file with log of all BBS communications
+  packet.log        ⇥text file with log of all BBS communications (see "packet help log")
+  packet.log.#      ⇥older logs of BBS communications
+  packet.sessions   ⇥summary of each BBS connection, in JSON format
+  packet.history    ⇥commands run in the packet shell (see "packet help history")
+  hook-«event»      ⇥programs to run on message events, if any (see "packet help hooks")
+  packet.journal    ⇥record of BBS operations in progress (exists only if a connection was interrupted)
 
-For messages that we received, LOC-111P.txt and LOC-111P.pdf contain the received message, LOC-111P.DR0.txt contains the delivery receipt we sent for the message, and REM-222P.txt and REM-222P.pdf are named with the Origin Message ID of the received message.
+For messages that we received, LOC-111P.txt and LOC-111P.pdf contain the received message, LOC-111P.DR0.txt contains the delivery receipt we sent for the message, LOC-111P.RR0.txt contains the read receipt we sent (or have queued to send) for the message, if any, and REM-222P.txt and REM-222P.pdf are named with the Origin Message ID of the received message.
 
 For messages that we sent, LOC-111P.txt and LOC-111P.pdf contain the sent message, LOC-111P.DR#.txt and LOC-111P.RR#.txt contain the receipts we received for the message, and REM-222P.txt and REM-222P.pdf are named with the destination stations' message IDs for the message we sent (which we pull from their delivery receipts).
 
-For outgoing messages that we haven't sent yet, LOC-111P.txt and LOC-111P.pdf contain the message; none of the other message files exist.  The message has an "X-Packet-Queued: true" header if it is queued to be sent.
+For outgoing messages that we haven't sent yet, LOC-111P.txt and LOC-111P.pdf contain the message; none of the other message files exist.  The message has an "X-Packet-Queued: true" header if it is queued to be sent.  If it is queued, LOC-111P.queue records when it was queued, which determines its place in the send queue.
 
 PDF files are created only if the program is built with PDF rendering support, and only for messages containing a known form type.
 `
 
+const hooksSlug = `running external programs on message events`
+const hooksHelp = `
+The "packet" command can run external programs ("hooks") when certain events happen during a BBS connection.  This can be used, for example, to update a wall display, ring a bell, or relay messages to a chat system.  The hook for each event is an executable file named "hook-«event»" (optionally with an extension, such as "hook-sent.bat" on Windows).  It is looked for first in the incident directory, and then in the ".packet-hooks" directory in the user's home directory.  The events are:
+
+  received            ⇥a private message was received
+  received-immediate  ⇥an immediate private message was received (the "received" hook is run as well)
+  receipt             ⇥a delivery receipt was received for a sent message
+  sent                ⇥a message was sent
+  connect-failed      ⇥no connection could be made to any BBS
+  bulletin            ⇥a bulletin was received
+
+Hooks are given information about the event in environment variables:
+
+  PACKET_EVENT     ⇥the name of the event
+  PACKET_LMI       ⇥the local message ID of the message (for "receipt", the message we sent)
+  PACKET_HANDLING  ⇥the handling order of the message: I, P, or R
+  PACKET_TYPE      ⇥the type tag of the message (e.g., "ICS213")
+  PACKET_SUBJECT   ⇥the subject line of the message
+  PACKET_FILE      ⇥the full path name of the message file
+  PACKET_BBS       ⇥the BBS being connected to
+  PACKET_AREA      ⇥the bulletin area (for "bulletin" only)
+  PACKET_ERROR     ⇥the reason for the failure (for "connect-failed" only)
+
+Hooks are started in the background; the "packet" command does not wait for them to finish, and it discards their output.  If a hook can't be started, a note is written to the "packet.log" file.
+`
+
 const scriptSlug = `how to use "packet" from scripts`
 const scriptHelp = `
 The "packet" commands provide script-friendly behavior when standard input and output are not a terminal.  In particular:
@@ -79,6 +125,12 @@ The "packet" commands provide script-friendly behavior when standard input and o
     - ⇥All colorization of the output is suppressed.
     - ⇥Commands that normally produce tables will produce CSV output.
     - ⇥Error messages are written to standard error instead of standard output.
+  - ⇥The detection of terminals can be overridden by giving the "--script" or "--no-script" option before the command (e.g., "packet --script list"), or by setting the PACKET_SCRIPT environment variable to "true" or "false".  In --script mode, neither standard input nor standard output is treated as a terminal.  In --no-script (interactive) mode, both are treated as terminals, even if they are not; for example, "packet --no-script list | less -R" shows the colorized table.  (In the shell, however, a command whose input or output is redirected to a file is always run with terminal detection.)  The command-line options take precedence over the environment variable.
+  - ⇥When the "--format json" option is given before the command (e.g., "packet --format json list"), or the PACKET_FORMAT environment variable is set to "json":
+    - ⇥Standard output is treated as not being a terminal, even if it is one.
+    - ⇥Commands that normally produce tables or name/value lists produce JSON output instead of CSV.  The output is a stream of JSON objects, one per line:  one object for each table row, or a single object for other output.
+    - ⇥The "show" command produces a single object describing the message, including its envelope data and all of its fields with their PackItForms tags, values, and validation problems.
+    - ⇥Error messages are written to standard error as JSON objects with an "error" key.
   - ⇥When either standard input or standard output is not a terminal:
     - ⇥The "new" command prints to standard output the local message ID of the new message, so that it can be used in subsequent commands.
     - ⇥The "new" command does not start an editor, and the "edit" command is not available.
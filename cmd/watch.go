@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/rothskeller/packet-shell/cio"
+	"github.com/rothskeller/packet-shell/config"
+
+	"github.com/spf13/pflag"
+)
+
+const (
+	watchSlug = `Connect to the BBS periodically, unattended`
+	watchHelp = `
+usage: packet watch [flags]
+  -b, --bbs «bbs»               ⇥connect to «bbs» only
+  -f, --frequency «interval»    ⇥set interval between connections (default 30m)
+  -v, --verbose                 ⇥show BBS conversation
+
+The "watch" command connects to the BBS periodically, sending and receiving messages, until it is interrupted with Ctrl-C.  It is intended for unattended operation, e.g. overnight or during long events.  Each connection is the same as that made by the "connect" command with no flags, including any scheduled bulletin checks (see "packet help bulletins").
+
+Connections are made at the interval given by the --frequency (-f) flag (e.g., "30m" or "2h15m").  A connection is also made sooner if a bulletin check comes due, or if a new message is queued to be sent (e.g., by a "packet queue" command run in another window).  If a connection fails, the next one is attempted after one minute, with the delay doubling after each further failure, up to the --frequency interval.
+
+After each connection, the "watch" command prints a one-line summary of it.  The summary is also written to the "packet.log" file.  Pressing Ctrl-C during a connection interrupts it just as for the "connect" command; pressing it between connections stops the "watch" command.
+`
+)
+
+// watchPoll is how often the watch command checks for newly queued messages.
+const watchPoll = 15 * time.Second
+
+// watchMinBackoff is the delay before retrying after a failed connection.
+const watchMinBackoff = time.Minute
+
+func cmdWatch(args []string) (err error) {
+	var (
+		bbs       string
+		frequency time.Duration
+		verbose   bool
+		backoff   time.Duration
+		next      time.Time
+		queued    map[string]bool
+		sigintch  = make(chan os.Signal, 1)
+		flags     = pflag.NewFlagSet("watch", pflag.ContinueOnError)
+	)
+	flags.StringVarP(&bbs, "bbs", "b", "", "connect to «bbs» only")
+	flags.DurationVarP(&frequency, "frequency", "f", 30*time.Minute, "time between connections")
+	flags.BoolVarP(&verbose, "verbose", "v", false, "show BBS conversation")
+	flags.Usage = func() {} // we do our own
+	if err = flags.Parse(args); err == pflag.ErrHelp {
+		return cmdHelp([]string{"watch"})
+	} else if err != nil {
+		cio.Error("%s", err.Error())
+		return usage(watchHelp)
+	}
+	if flags.NArg() != 0 {
+		return usage(watchHelp)
+	}
+	if frequency <= 0 {
+		cio.Error("--frequency must be a positive duration")
+		return usage(watchHelp)
+	}
+	if !haveConnectConfig() {
+		return errors.New("missing necessary configuration settings")
+	}
+	// Trap ^C for as long as we're watching, so that one pressed between
+	// connections stops the watch rather than killing the process.  (During
+	// a connection, the connection traps it too, and is interrupted by
+	// it.)
+	signal.Notify(sigintch, os.Interrupt)
+	defer signal.Stop(sigintch)
+	fmt.Printf("Connecting to the BBS every %s.  Press Ctrl-C to stop.\n", frequency)
+	next = time.Now()
+	for {
+		// Wait for the next connection to be due.
+		if waitForSession(next, queued, sigintch) {
+			fmt.Println("Stopped watching.")
+			return nil
+		}
+		// Pick up any changes made by other commands since the last
+		// connection, and note what's queued now, so that we can tell
+		// when something new is queued.
+		config.Reload()
+		queued = queuedMessages()
+		// Don't start the connection if ^C was pressed meanwhile.
+		select {
+		case <-sigintch:
+			fmt.Println("Stopped watching.")
+			return nil
+		default:
+		}
+		// Run the connection.
+		conn := connection{rcvlevel: 1, unattended: true}
+		start := time.Now()
+		err = conn.session(1, bbs, verbose)
+		if conn.interrupted {
+			watchSummary(start, &conn, ErrInterrupted)
+			return nil
+		}
+		watchSummary(start, &conn, err)
+		// Schedule the next connection.
+		if err != nil {
+			backoff = min(max(backoff*2, watchMinBackoff), frequency)
+			next = time.Now().Add(backoff)
+			continue
+		}
+		backoff = 0
+		next = time.Now().Add(frequency)
+		for _, bc := range config.C.Bulletins {
+			if due := bc.LastCheck.Add(bc.Frequency); due.Before(next) {
+				next = due
+			}
+		}
+	}
+}
+
+// waitForSession waits until the next connection is due:  either the
+// scheduled time has arrived, or a message has been queued that isn't in the
+// queued map.  It returns true if the wait was interrupted by Ctrl-C.
+func waitForSession(next time.Time, queued map[string]bool, sigintch chan os.Signal) (stop bool) {
+	var (
+		ticker = time.NewTicker(watchPoll)
+		timer  = time.NewTimer(time.Until(next))
+	)
+	defer ticker.Stop()
+	defer timer.Stop()
+	cio.Status("Next connection at %s...", next.Format("15:04"))
+	defer cio.Status("")
+	for {
+		select {
+		case <-sigintch:
+			return true
+		case <-timer.C:
+			return false
+		case <-ticker.C:
+			for lmi := range queuedMessages() {
+				if !queued[lmi] {
+					return false
+				}
+			}
+		}
+	}
+}
+
+// queuedMessages returns the set of messages currently queued to be sent.
+func queuedMessages() (queued map[string]bool) {
+	tosend, _, _ := preConnectScan(1, nil)
+	queued = make(map[string]bool, len(tosend))
+	for _, lmi := range tosend {
+		queued[lmi] = true
+	}
+	return queued
+}
+
+// watchSummary prints, and logs, a one-line summary of a connection.
+func watchSummary(start time.Time, conn *connection, err error) {
+	var line string
+
+	line = start.Format("2006-01-02 15:04:05")
+	if conn.bbs != nil {
+		line += " " + conn.bbs.BBS
+	}
+	line += fmt.Sprintf(": %d sent, %d received", conn.sent, conn.received)
+	if err != nil {
+		line += fmt.Sprintf("; %s", err)
+	}
+	fmt.Println(line)
+	if logfile, err := os.OpenFile(logFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666); err == nil {
+		fmt.Fprintf(logfile, "[watch: %s]\n", line)
+		logfile.Close()
+	}
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"sort"
+
+	"golang.org/x/exp/maps"
+)
+
+// User-defined command aliases are stored in two places.  Those defined for a
+// single incident are in C.Aliases, which is saved in packet.conf.  Those
+// defined for all incidents are in C.homeAliases, which is saved in
+// $HOME/.packet.  When both define the same name, the incident one wins.
+
+// Alias returns the expansion of the named user-defined alias, and whether
+// there is one.
+func (c *PacketConfig) Alias(name string) (expansion string, ok bool) {
+	if expansion, ok = c.Aliases[name]; ok {
+		return expansion, true
+	}
+	expansion, ok = c.homeAliases[name]
+	return expansion, ok
+}
+
+// AliasNames returns the sorted names of all user-defined aliases.
+func (c *PacketConfig) AliasNames() (names []string) {
+	names = maps.Keys(c.homeAliases)
+	for name := range c.Aliases {
+		if _, ok := c.homeAliases[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasAlias returns whether the named alias is defined for this incident, and
+// whether it is defined for all incidents.
+func (c *PacketConfig) HasAlias(name string) (local, global bool) {
+	_, local = c.Aliases[name]
+	_, global = c.homeAliases[name]
+	return local, global
+}
+
+// IsGlobalAlias returns whether the named alias is defined for all incidents
+// (and not overridden for this one).
+func (c *PacketConfig) IsGlobalAlias(name string) bool {
+	if _, ok := c.Aliases[name]; ok {
+		return false
+	}
+	_, ok := c.homeAliases[name]
+	return ok
+}
+
+// SetAlias defines a user-defined alias, or removes it if the expansion is
+// empty.  If global is true, the alias applies to all incidents; otherwise, it
+// applies only to this one.  The change is not saved until SaveConfig is
+// called.
+func (c *PacketConfig) SetAlias(name, expansion string, global bool) {
+	var aliases = &c.Aliases
+
+	if global {
+		aliases = &c.homeAliases
+	}
+	if expansion == "" {
+		delete(*aliases, name)
+		return
+	}
+	if *aliases == nil {
+		*aliases = make(map[string]string)
+	}
+	(*aliases)[name] = expansion
+}
@@ -42,14 +42,23 @@ type PacketConfig struct {
 	BBS                 string                     `json:",omitempty"`
 	BBSAddress          string                     `json:",omitempty"`
 	SerialPort          string                     `json:",omitempty"`
+	TNCType             string                     `json:",omitempty"`
+	KISSBaud            string                     `json:",omitempty"`
+	KISSTxDelay         string                     `json:",omitempty"`
+	KISSPersist         string                     `json:",omitempty"`
+	TNCHost             string                     `json:",omitempty"`
+	TNCPort             string                     `json:",omitempty"`
+	AGWRadioPort        string                     `json:",omitempty"`
 	OpCall              string                     `json:",omitempty"`
 	OpName              string                     `json:",omitempty"`
 	TacCall             string                     `json:",omitempty"`
 	TacName             string                     `json:",omitempty"`
 	TacRequested        bool                       `json:",omitempty"`
 	Password            string                     `json:",omitempty"`
+	BackupBBSes         []*BBSProfile              `json:",omitempty"`
 	TxMessageID         string                     `json:",omitempty"`
 	RxMessageID         string                     `json:",omitempty"`
+	SendReadReceipts    bool                       `json:",omitempty"`
 	DefDest             string                     `json:",omitempty"`
 	DefToPosition       string                     `json:",omitempty"`
 	DefToLocation       string                     `json:",omitempty"`
@@ -57,14 +66,17 @@ type PacketConfig struct {
 	DefFromLocation     string                     `json:",omitempty"`
 	DefBody             string                     `json:",omitempty"`
 	Bulletins           map[string]*BulletinConfig `json:",omitempty"`
+	Aliases             map[string]string          `json:",omitempty"`
 	UnreadList          []string                   `json:"Unread,omitempty"`
 	Unread              map[string]bool            `json:"-"`
 	// Unread isn't really a "configuration" setting, but it's convenient to
 	// keep it in the packet.conf file anyway.
-	connType string
-	ax25addr string
-	hostname string
-	port     string
+	connType    string
+	ax25addr    string
+	hostname    string
+	port        string
+	readRcpt    string
+	homeAliases map[string]string // see aliases.go
 }
 type BulletinConfig struct {
 	Frequency time.Duration
@@ -78,6 +90,19 @@ var (
 )
 
 func init() {
+	load()
+}
+
+// Reload discards the current configuration and reads it again from the
+// configuration files.  It is used by long-running commands, to pick up changes
+// made by other "packet" commands in the meantime.
+func Reload() {
+	C = PacketConfig{}
+	load()
+}
+
+// load reads the configuration from the configuration files.
+func load() {
 	C.Unread = make(map[string]bool)
 	C.SerialPort = guessSerialPort()
 	// The last configuration saved for any session was also saved to
@@ -87,6 +112,9 @@ func init() {
 	if home := os.Getenv("HOME"); home != "" {
 		readConfig(filepath.Join(home, packetDefaults))
 	}
+	// Aliases from $HOME/.packet are kept separately from those in the
+	// local directory, so that each is saved back to the right place.
+	C.homeAliases, C.Aliases = C.Aliases, nil
 	// Then read the config in the local directory, if any, to override
 	// those.
 	readConfig(packetConf)
@@ -184,12 +212,21 @@ func SaveConfig() {
 		return
 	}
 	reduced := PacketConfig{
-		BBS:        C.BBS,
-		BBSAddress: C.BBSAddress,
-		SerialPort: C.SerialPort,
-		OpCall:     C.OpCall,
-		OpName:     C.OpName,
-		Password:   C.Password,
+		BBS:          C.BBS,
+		BBSAddress:   C.BBSAddress,
+		SerialPort:   C.SerialPort,
+		TNCType:      C.TNCType,
+		KISSBaud:     C.KISSBaud,
+		KISSTxDelay:  C.KISSTxDelay,
+		KISSPersist:  C.KISSPersist,
+		TNCHost:      C.TNCHost,
+		TNCPort:      C.TNCPort,
+		AGWRadioPort: C.AGWRadioPort,
+		OpCall:       C.OpCall,
+		OpName:       C.OpName,
+		Password:     C.Password,
+		BackupBBSes:  C.BackupBBSes,
+		Aliases:      C.homeAliases,
 	}
 	by, _ = json.Marshal(&reduced)
 	if err = os.WriteFile(filepath.Join(home, packetDefaults), by, 0666); err != nil {
@@ -197,6 +234,33 @@ func SaveConfig() {
 	}
 }
 
+// radioConnType returns whether the specified "BBS Connection" value is one
+// that connects to the BBS over the air.
+func radioConnType(ct string) bool {
+	return ct == "Radio" || ct == "Radio (KISS)" || ct == "Radio (KISS/TCP)" || ct == "Radio (AGWPE)"
+}
+
+// tcpConnType returns whether the specified "BBS Connection" value is one that
+// reaches the TNC (or soundcard modem program) over TCP.
+func tcpConnType(ct string) bool {
+	return ct == "Radio (KISS/TCP)" || ct == "Radio (AGWPE)"
+}
+
+// defaultTNCPort returns the default TCP port number for the TNC, for the
+// current "BBS Connection" value.
+func defaultTNCPort() int {
+	if C.connType == "Radio (AGWPE)" {
+		return 8000
+	}
+	return 8001
+}
+
+// serialConnType returns whether the specified "BBS Connection" value is one
+// that uses a TNC attached to a serial port.
+func serialConnType(ct string) bool {
+	return ct == "Radio" || ct == "Radio (KISS)"
+}
+
 func makeConfigFields() []*message.Field {
 	if strings.Contains(C.BBSAddress, ":") {
 		C.connType, C.ax25addr = "Internet", ""
@@ -207,11 +271,22 @@ func makeConfigFields() []*message.Field {
 		} else {
 			C.port = ""
 		}
+	} else if C.BBSAddress != "" && C.TNCType == "KISS" {
+		C.connType, C.ax25addr, C.hostname, C.port = "Radio (KISS)", C.BBSAddress, "", ""
+	} else if C.BBSAddress != "" && C.TNCType == "KISS/TCP" {
+		C.connType, C.ax25addr, C.hostname, C.port = "Radio (KISS/TCP)", C.BBSAddress, "", ""
+	} else if C.BBSAddress != "" && C.TNCType == "AGWPE" {
+		C.connType, C.ax25addr, C.hostname, C.port = "Radio (AGWPE)", C.BBSAddress, "", ""
 	} else if C.BBSAddress != "" {
 		C.connType, C.ax25addr, C.hostname, C.port = "Radio", C.BBSAddress, "", ""
 	} else {
 		C.connType, C.ax25addr, C.hostname, C.port = "", "", "", ""
 	}
+	if C.SendReadReceipts {
+		C.readRcpt = "Yes"
+	} else {
+		C.readRcpt = "No"
+	}
 	return []*message.Field{
 		message.NewFCCCallSignField(&message.Field{
 			Label:    "Operator Call Sign",
@@ -244,23 +319,45 @@ func makeConfigFields() []*message.Field {
 		message.NewRestrictedField(&message.Field{
 			Label:      "BBS Connection",
 			Value:      &C.connType,
-			Choices:    message.Choices{"Radio", "Internet"},
+			Choices:    message.Choices{"Radio", "Radio (KISS)", "Radio (KISS/TCP)", "Radio (AGWPE)", "Internet"},
 			Presence:   message.Required,
 			TableValue: message.TableOmit,
-			EditHelp:   `This specifies how the "packet" command will connect to the BBS.  "Radio" means connecting to the BBS over the air, by way of a Kantronics KPC-3 Plus or compatible TNC connected to a radio transceiver.  "Internet" means connecting to the BBS over the Internet.  The choice is required.`,
+			EditHelp:   `This specifies how the "packet" command will connect to the BBS.  "Radio" means connecting to the BBS over the air, by way of a Kantronics KPC-3 Plus or compatible TNC connected to a radio transceiver.  "Radio (KISS)" means connecting to the BBS over the air, by way of a KISS-mode TNC (such as a Mobilinkd, NinoTNC, or TNC-Pi) connected to a radio transceiver.  "Radio (KISS/TCP)" means connecting to the BBS over the air, by way of a soundcard modem program (such as Direwolf) that provides a KISS interface over TCP.  "Radio (AGWPE)" means connecting to the BBS over the air, by way of a soundcard modem program (such as AGWPE, SoundModem, or Direwolf) that provides the AGWPE interface over TCP.  "Internet" means connecting to the BBS over the Internet.  The choice is required.`,
 			EditApply: func(f *message.Field, s string) {
 				nv := f.Choices.ToPIFO(strings.TrimSpace(s))
-				if nv != C.connType {
+				if nv != C.connType && !(radioConnType(nv) && radioConnType(C.connType)) {
 					C.BBS = ""
 					C.BBSAddress = ""
 				}
-				if nv == "Radio" {
+				if radioConnType(nv) {
 					C.hostname = ""
 					C.port = ""
 					C.Password = ""
 				} else {
 					C.ax25addr = ""
 				}
+				switch nv {
+				case "Radio (KISS)":
+					C.TNCType = "KISS"
+				case "Radio (KISS/TCP)":
+					C.TNCType = "KISS/TCP"
+				case "Radio (AGWPE)":
+					C.TNCType = "AGWPE"
+				default:
+					C.TNCType = ""
+				}
+				// Clear the TNC settings that don't apply to the new
+				// connection type.  They would not be shown in the
+				// editor, but would fail validation.
+				if nv != "Radio (KISS)" {
+					C.KISSBaud, C.KISSTxDelay, C.KISSPersist = "", "", ""
+				}
+				if !tcpConnType(nv) {
+					C.TNCHost, C.TNCPort = "", ""
+				}
+				if nv != "Radio (AGWPE)" {
+					C.AGWRadioPort = ""
+				}
 				C.connType = nv
 			},
 		}),
@@ -268,14 +365,14 @@ func makeConfigFields() []*message.Field {
 			Label: "BBS Address",
 			Value: &C.ax25addr,
 			Presence: func() (message.Presence, string) {
-				if C.connType == "Radio" {
-					return message.PresenceRequired, `when the "BBS Connection" is "Radio"`
+				if radioConnType(C.connType) {
+					return message.PresenceRequired, `when the "BBS Connection" is one of the "Radio" types`
 				} else {
-					return message.PresenceNotAllowed, `unless the "BBS Connection" is "Radio"`
+					return message.PresenceNotAllowed, `unless the "BBS Connection" is one of the "Radio" types`
 				}
 			},
 			EditHint: "e.g. W6XSC-1",
-			EditHelp: `This is the AX.25 address of the BBS.  It must be an FCC call sign followed by a hyphen and an integer (usually 1).  It is required when the "BBS Connection" is "Radio".`,
+			EditHelp: `This is the AX.25 address of the BBS.  It must be an FCC call sign followed by a hyphen and an integer (usually 1).  It is required when the "BBS Connection" is one of the "Radio" types.`,
 			EditApply: func(f *message.Field, s string) {
 				C.ax25addr = strings.ToUpper(strings.TrimSpace(s))
 				C.BBSAddress = C.ax25addr
@@ -300,19 +397,19 @@ func makeConfigFields() []*message.Field {
 			Value:   &C.SerialPort,
 			Choices: message.Choices(possiblePorts),
 			Presence: func() (message.Presence, string) {
-				if C.connType == "Radio" {
-					return message.PresenceRequired, `when the "BBS Connection" is "Radio"`
+				if serialConnType(C.connType) {
+					return message.PresenceRequired, `when the "BBS Connection" is "Radio" or "Radio (KISS)"`
 				} else {
 					return message.PresenceOptional, ""
 				}
 			},
 			TableValue: func(f *message.Field) string {
-				if C.connType != "Radio" {
+				if !serialConnType(C.connType) {
 					return ""
 				}
 				return C.SerialPort
 			},
-			EditHelp: `This is the serial port for communications with the TNC.  On Windows, this will be COM#, where # is some number.  On other systems, this will be a filename of a character device file in /dev.  It is required when the "BBS Connection" is "Radio".`,
+			EditHelp: `This is the serial port for communications with the TNC.  On Windows, this will be COM#, where # is some number.  On other systems, this will be a filename of a character device file in /dev.  It is required when the "BBS Connection" is "Radio" or "Radio (KISS)".`,
 			EditApply: func(f *message.Field, s string) {
 				s = strings.TrimSpace(s)
 				if runtime.GOOS == "windows" {
@@ -336,7 +433,198 @@ func makeConfigFields() []*message.Field {
 				return ""
 			},
 			EditSkip: func(f *message.Field) bool {
-				return C.connType != "Radio"
+				return !serialConnType(C.connType)
+			},
+		}),
+		message.NewCardinalNumberField(&message.Field{
+			Label: "TNC Baud Rate",
+			Value: &C.KISSBaud,
+			Presence: func() (message.Presence, string) {
+				if C.connType == "Radio (KISS)" {
+					return message.PresenceOptional, ""
+				} else {
+					return message.PresenceNotAllowed, `unless the "BBS Connection" is "Radio (KISS)"`
+				}
+			},
+			TableValue: func(f *message.Field) string {
+				if C.connType != "Radio (KISS)" {
+					return ""
+				}
+				return C.KISSBaud
+			},
+			EditHint: "default 9600",
+			EditHelp: `This is the speed, in bits per second, of the serial port connection to the KISS TNC.  (This is not the speed of the radio channel.)  It should match the setting of the TNC; consult its documentation.  If it is not specified, 9600 is used.  It is allowed only when the "BBS Connection" is "Radio (KISS)".`,
+			EditValid: func(f *message.Field) string {
+				if p := f.PresenceValid(); p != "" {
+					return p
+				}
+				if n, err := strconv.Atoi(C.KISSBaud); C.KISSBaud != "" && (err != nil || n < 300 || n > 921600) {
+					return `The "TNC Baud Rate" field does not contain a valid serial port speed.`
+				}
+				return ""
+			},
+			EditSkip: func(f *message.Field) bool {
+				return C.connType != "Radio (KISS)"
+			},
+		}),
+		message.NewCardinalNumberField(&message.Field{
+			Label: "TNC TXDELAY",
+			Value: &C.KISSTxDelay,
+			Presence: func() (message.Presence, string) {
+				if C.connType == "Radio (KISS)" {
+					return message.PresenceOptional, ""
+				} else {
+					return message.PresenceNotAllowed, `unless the "BBS Connection" is "Radio (KISS)"`
+				}
+			},
+			TableValue: func(f *message.Field) string {
+				if C.connType != "Radio (KISS)" || C.KISSTxDelay == "" {
+					return ""
+				}
+				return C.KISSTxDelay + " ms"
+			},
+			EditHint: "milliseconds, default 300",
+			EditHelp: `This is the delay, in milliseconds, between keying the transmitter and starting to send data.  It gives the radio time to get up to full power.  If it is not specified, 300 milliseconds is used.  It is allowed only when the "BBS Connection" is "Radio (KISS)".`,
+			EditValid: func(f *message.Field) string {
+				if p := f.PresenceValid(); p != "" {
+					return p
+				}
+				if n, err := strconv.Atoi(C.KISSTxDelay); C.KISSTxDelay != "" && (err != nil || n < 10 || n > 2550) {
+					return `The "TNC TXDELAY" field must be a number of milliseconds between 10 and 2550.`
+				}
+				return ""
+			},
+			EditSkip: func(f *message.Field) bool {
+				return C.connType != "Radio (KISS)"
+			},
+		}),
+		message.NewCardinalNumberField(&message.Field{
+			Label: "TNC Persistence",
+			Value: &C.KISSPersist,
+			Presence: func() (message.Presence, string) {
+				if C.connType == "Radio (KISS)" {
+					return message.PresenceOptional, ""
+				} else {
+					return message.PresenceNotAllowed, `unless the "BBS Connection" is "Radio (KISS)"`
+				}
+			},
+			TableValue: func(f *message.Field) string {
+				if C.connType != "Radio (KISS)" {
+					return ""
+				}
+				return C.KISSPersist
+			},
+			EditHint: "0-255, default 63",
+			EditHelp: `This is the persistence parameter for the TNC's channel access algorithm.  When the channel is clear, the TNC will transmit with probability (P+1)/256, where P is this parameter.  If it is not specified, 63 is used.  It is allowed only when the "BBS Connection" is "Radio (KISS)".`,
+			EditValid: func(f *message.Field) string {
+				if p := f.PresenceValid(); p != "" {
+					return p
+				}
+				if n, err := strconv.Atoi(C.KISSPersist); C.KISSPersist != "" && (err != nil || n < 0 || n > 255) {
+					return `The "TNC Persistence" field must be a number between 0 and 255.`
+				}
+				return ""
+			},
+			EditSkip: func(f *message.Field) bool {
+				return C.connType != "Radio (KISS)"
+			},
+		}),
+		message.NewTextField(&message.Field{
+			Label: "TNC Hostname",
+			Value: &C.TNCHost,
+			Presence: func() (message.Presence, string) {
+				if tcpConnType(C.connType) {
+					return message.PresenceOptional, ""
+				} else {
+					return message.PresenceNotAllowed, `unless the "BBS Connection" is "Radio (KISS/TCP)" or "Radio (AGWPE)"`
+				}
+			},
+			TableValue: message.TableOmit,
+			EditHint:   "default localhost",
+			EditHelp:   `This is the hostname (or IP address) of the computer running the soundcard modem program that provides the KISS or AGWPE interface.  If it is not specified, "localhost" is used.  It is allowed only when the "BBS Connection" is "Radio (KISS/TCP)" or "Radio (AGWPE)".`,
+			EditApply: func(f *message.Field, s string) {
+				C.TNCHost = strings.TrimSpace(s)
+			},
+			EditValid: func(f *message.Field) string {
+				if p := f.PresenceValid(); p != "" {
+					return p
+				}
+				if host, _, err := net.SplitHostPort(C.TNCHost + ":1"); C.TNCHost != "" && (err != nil || host != C.TNCHost) {
+					return `The "TNC Hostname" field does not contain a valid hostname.`
+				}
+				return ""
+			},
+			EditSkip: func(f *message.Field) bool {
+				return !tcpConnType(C.connType)
+			},
+		}),
+		message.NewCardinalNumberField(&message.Field{
+			Label: "TNC Port Number",
+			Value: &C.TNCPort,
+			Presence: func() (message.Presence, string) {
+				if tcpConnType(C.connType) {
+					return message.PresenceOptional, ""
+				} else {
+					return message.PresenceNotAllowed, `unless the "BBS Connection" is "Radio (KISS/TCP)" or "Radio (AGWPE)"`
+				}
+			},
+			TableValue: message.TableOmit,
+			EditHint:   "default 8001 for KISS, 8000 for AGWPE",
+			EditHelp:   `This is the TCP port number on which the soundcard modem program provides the KISS or AGWPE interface.  If it is not specified, 8001 (the Direwolf KISS default) is used for "Radio (KISS/TCP)", and 8000 (the AGWPE default) is used for "Radio (AGWPE)".  It is allowed only when the "BBS Connection" is "Radio (KISS/TCP)" or "Radio (AGWPE)".`,
+			EditValid: func(f *message.Field) string {
+				if p := f.PresenceValid(); p != "" {
+					return p
+				}
+				if n, err := strconv.Atoi(C.TNCPort); C.TNCPort != "" && (err != nil || n < 1 || n > 65535) {
+					return `The "TNC Port Number" field does not contain a valid Internet port number.`
+				}
+				return ""
+			},
+			EditSkip: func(f *message.Field) bool {
+				return !tcpConnType(C.connType)
+			},
+		}),
+		message.NewCardinalNumberField(&message.Field{
+			Label: "AGW Radio Port",
+			Value: &C.AGWRadioPort,
+			Presence: func() (message.Presence, string) {
+				if C.connType == "Radio (AGWPE)" {
+					return message.PresenceOptional, ""
+				} else {
+					return message.PresenceNotAllowed, `unless the "BBS Connection" is "Radio (AGWPE)"`
+				}
+			},
+			TableValue: func(f *message.Field) string {
+				if C.connType != "Radio (AGWPE)" {
+					return ""
+				}
+				if C.AGWRadioPort == "" {
+					return "0"
+				}
+				return C.AGWRadioPort
+			},
+			EditHint: "default 0",
+			EditHelp: `This is the number of the AGWPE radio port through which to connect to the BBS.  Radio ports are numbered starting from zero; for Direwolf, this is the channel number.  If it is not specified, 0 is used.  It is allowed only when the "BBS Connection" is "Radio (AGWPE)".`,
+			EditValid: func(f *message.Field) string {
+				if p := f.PresenceValid(); p != "" {
+					return p
+				}
+				if n, err := strconv.Atoi(C.AGWRadioPort); C.AGWRadioPort != "" && (err != nil || n < 0 || n > 255) {
+					return `The "AGW Radio Port" field must be a number between 0 and 255.`
+				}
+				return ""
+			},
+			EditSkip: func(f *message.Field) bool {
+				return C.connType != "Radio (AGWPE)"
+			},
+		}),
+		message.NewAggregatorField(&message.Field{
+			Label: "TNC Address",
+			TableValue: func(f *message.Field) string {
+				if tcpConnType(C.connType) {
+					return C.Primary().TNCAddress(defaultTNCPort())
+				}
+				return ""
 			},
 		}),
 		message.NewTextField(&message.Field{
@@ -434,6 +722,16 @@ func makeConfigFields() []*message.Field {
 			HideValue:  true,
 			EditHelp:   `This is the password for logging into the BBS server using the "Tactical Call Sign" (if provided) or "Operator Call Sign".  It is required when the "BBS Connection" is "Internet".  Note that this password will be saved in clear text in the local "packet.conf" file; make sure to protect it appropriately.`,
 		}),
+		message.NewAggregatorField(&message.Field{
+			Label: "Backup BBSes",
+			TableValue: func(f *message.Field) string {
+				var names []string
+				for _, p := range C.BackupBBSes {
+					names = append(names, p.BBS)
+				}
+				return strings.Join(names, ", ")
+			},
+		}),
 		message.NewMessageNumberField(&message.Field{
 			Label:    "Message Numbering",
 			Value:    &C.RxMessageID,
@@ -456,6 +754,16 @@ func makeConfigFields() []*message.Field {
 				}
 			},
 		}),
+		message.NewRestrictedField(&message.Field{
+			Label:    "Send Read Receipts",
+			Value:    &C.readRcpt,
+			Choices:  message.Choices{"Yes", "No"},
+			EditHelp: `This indicates whether read receipts should be sent for received messages.  If it is "Yes", a read receipt is queued for each received message the first time it is viewed (with the "show", "pdf", or "dump" command), and is sent to the message's sender during the next BBS connection.  Delivery receipts are always sent, regardless of this setting.`,
+			EditApply: func(f *message.Field, s string) {
+				C.readRcpt = f.Choices.ToPIFO(strings.TrimSpace(s))
+				C.SendReadReceipts = C.readRcpt == "Yes"
+			},
+		}),
 		message.NewAddressListField(&message.Field{
 			Label:    "Default Destination",
 			Value:    &C.DefDest,
@@ -0,0 +1,105 @@
+package config
+
+import (
+	"net"
+	"strconv"
+	"strings"
+)
+
+// A BBSProfile is a set of BBS connection settings.  The primary profile is
+// stored in the main configuration settings (which is what "edit config"
+// changes); backup profiles are stored in C.BackupBBSes, in the order they
+// should be tried.  Profiles are identified by the BBS call sign.
+type BBSProfile struct {
+	BBS          string `json:",omitempty"`
+	BBSAddress   string `json:",omitempty"`
+	SerialPort   string `json:",omitempty"`
+	TNCType      string `json:",omitempty"`
+	KISSBaud     string `json:",omitempty"`
+	KISSTxDelay  string `json:",omitempty"`
+	KISSPersist  string `json:",omitempty"`
+	TNCHost      string `json:",omitempty"`
+	TNCPort      string `json:",omitempty"`
+	AGWRadioPort string `json:",omitempty"`
+	Password     string `json:",omitempty"`
+}
+
+// Primary returns the primary BBS connection profile.
+func (c *PacketConfig) Primary() *BBSProfile {
+	return &BBSProfile{
+		BBS:          c.BBS,
+		BBSAddress:   c.BBSAddress,
+		SerialPort:   c.SerialPort,
+		TNCType:      c.TNCType,
+		KISSBaud:     c.KISSBaud,
+		KISSTxDelay:  c.KISSTxDelay,
+		KISSPersist:  c.KISSPersist,
+		TNCHost:      c.TNCHost,
+		TNCPort:      c.TNCPort,
+		AGWRadioPort: c.AGWRadioPort,
+		Password:     c.Password,
+	}
+}
+
+// SetPrimary replaces the primary BBS connection profile.
+func (c *PacketConfig) SetPrimary(p *BBSProfile) {
+	c.BBS = p.BBS
+	c.BBSAddress = p.BBSAddress
+	c.SerialPort = p.SerialPort
+	c.TNCType = p.TNCType
+	c.KISSBaud = p.KISSBaud
+	c.KISSTxDelay = p.KISSTxDelay
+	c.KISSPersist = p.KISSPersist
+	c.TNCHost = p.TNCHost
+	c.TNCPort = p.TNCPort
+	c.AGWRadioPort = p.AGWRadioPort
+	c.Password = p.Password
+	c.Fields = makeConfigFields()
+}
+
+// Profiles returns all BBS connection profiles, in the order they should be
+// tried:  the primary profile first, followed by the backup profiles.
+func (c *PacketConfig) Profiles() (profiles []*BBSProfile) {
+	if c.BBS != "" {
+		profiles = append(profiles, c.Primary())
+	}
+	return append(profiles, c.BackupBBSes...)
+}
+
+// FindProfile returns the BBS connection profile for the named BBS, or nil if
+// there is none.
+func (c *PacketConfig) FindProfile(bbs string) *BBSProfile {
+	for _, p := range c.Profiles() {
+		if strings.EqualFold(p.BBS, bbs) {
+			return p
+		}
+	}
+	return nil
+}
+
+// ConnType returns a description of the type of connection a profile makes, in
+// the form used by the "BBS Connection" setting.
+func (p *BBSProfile) ConnType() string {
+	switch {
+	case strings.Contains(p.BBSAddress, ":"):
+		return "Internet"
+	case p.TNCType != "":
+		return "Radio (" + p.TNCType + ")"
+	default:
+		return "Radio"
+	}
+}
+
+// TNCAddress returns the host:port address of the TNC for connection types
+// that reach it over TCP, applying defaults for unspecified settings.
+func (p *BBSProfile) TNCAddress(defport int) string {
+	var host, port = p.TNCHost, p.TNCPort
+
+	if host == "" {
+		host = "localhost"
+	}
+	if port == "" {
+		port = strconv.Itoa(defport)
+	}
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,609 @@
+package kiss
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AX.25 connected-mode parameters.  These are conservative values suitable
+// for a 1200-baud BBS channel.
+const (
+	modulus    = 8   // sequence numbers are modulo 8
+	window     = 4   // maximum unacknowledged I frames (k)
+	paclen     = 128 // maximum I frame information length
+	maxRetries = 10  // maximum transmissions of an unanswered frame (N2)
+	airRate    = 1200
+)
+
+// Frame control field values.
+const (
+	ctlRR   = 0x01
+	ctlRNR  = 0x05
+	ctlREJ  = 0x09
+	ctlUI   = 0x03
+	ctlSABM = 0x2F
+	ctlDISC = 0x43
+	ctlDM   = 0x0F
+	ctlUA   = 0x63
+	ctlFRMR = 0x87
+	ctlPF   = 0x10
+	pidNone = 0xF0
+)
+
+var ax25AddressRE = regexp.MustCompile(`^([A-Z0-9]{1,6})(?:-(1[0-5]|[0-9]))?$`)
+
+// An address is an AX.25 station address.
+type address struct {
+	call string
+	ssid byte
+}
+
+// parseAddress parses an AX.25 address in CALL-SSID form.
+func parseAddress(s string) (a address, err error) {
+	match := ax25AddressRE.FindStringSubmatch(strings.ToUpper(s))
+	if match == nil {
+		return a, fmt.Errorf("%q is not a valid AX.25 address", s)
+	}
+	a.call = match[1]
+	if match[2] != "" {
+		ssid, _ := strconv.Atoi(match[2])
+		a.ssid = byte(ssid)
+	}
+	return a, nil
+}
+
+func (a address) String() string {
+	if a.ssid == 0 {
+		return a.call
+	}
+	return fmt.Sprintf("%s-%d", a.call, a.ssid)
+}
+
+// encode appends the encoded form of the address to buf.  cbit is the
+// command/response bit for the address, and last indicates that this is the
+// last address in the address field.
+func (a address) encode(buf []byte, cbit, last bool) []byte {
+	for i := 0; i < 6; i++ {
+		if i < len(a.call) {
+			buf = append(buf, a.call[i]<<1)
+		} else {
+			buf = append(buf, ' '<<1)
+		}
+	}
+	ssid := 0x60 | a.ssid<<1
+	if cbit {
+		ssid |= 0x80
+	}
+	if last {
+		ssid |= 0x01
+	}
+	return append(buf, ssid)
+}
+
+// decodeAddress decodes a seven-byte encoded address.
+func decodeAddress(b []byte) (a address, cbit, last bool) {
+	var call [6]byte
+
+	for i := 0; i < 6; i++ {
+		call[i] = b[i] >> 1
+	}
+	a.call = strings.TrimRight(string(call[:]), " ")
+	a.ssid = (b[6] >> 1) & 0x0F
+	return a, b[6]&0x80 != 0, b[6]&0x01 != 0
+}
+
+// A frame is a decoded AX.25 frame.
+type frame struct {
+	dest, src address
+	command   bool
+	control   byte
+	info      []byte
+}
+
+// encode returns the encoded form of the frame.
+func (f *frame) encode() []byte {
+	var buf = make([]byte, 0, 16+len(f.info))
+
+	buf = f.dest.encode(buf, f.command, false)
+	buf = f.src.encode(buf, !f.command, true)
+	buf = append(buf, f.control)
+	if f.control&0x01 == 0 || f.control&^ctlPF == ctlUI {
+		buf = append(buf, pidNone)
+		buf = append(buf, f.info...)
+	}
+	return buf
+}
+
+// decodeFrame decodes a received frame.  Frames that came through digipeaters
+// are accepted; the digipeater addresses are ignored.
+func decodeFrame(b []byte) (f *frame, err error) {
+	var dcbit, scbit, last bool
+
+	if len(b) < 15 {
+		return nil, errors.New("frame too short")
+	}
+	f = new(frame)
+	f.dest, dcbit, _ = decodeAddress(b[0:7])
+	f.src, scbit, last = decodeAddress(b[7:14])
+	b = b[14:]
+	for !last {
+		if len(b) < 8 {
+			return nil, errors.New("frame too short")
+		}
+		_, _, last = decodeAddress(b[0:7])
+		b = b[7:]
+	}
+	f.command = dcbit && !scbit
+	f.control, b = b[0], b[1:]
+	if f.control&0x01 == 0 || f.control&^ctlPF == ctlUI {
+		if len(b) < 1 {
+			return nil, errors.New("missing PID")
+		}
+		f.info = b[1:]
+	}
+	return f, nil
+}
+
+// linkState is the state of an AX.25 link.
+type linkState int
+
+const (
+	stateDisconnected linkState = iota
+	stateConnecting
+	stateConnected
+	stateDisconnecting
+)
+
+// A link is an AX.25 connected-mode link to a remote station.  It implements
+// io.ReadWriteCloser, presenting the link as a byte stream.
+type link struct {
+	f      *framer
+	local  address
+	remote address
+	opcall string
+	log    io.Writer
+	t1     time.Duration
+	t2     time.Duration
+	done   chan struct{}
+
+	mu         sync.Mutex
+	cond       *sync.Cond
+	state      linkState
+	vs, va, vr int
+	sendq      []byte
+	sent       [modulus][]byte
+	rbuf       []byte
+	remoteBusy bool
+	rejSent    bool
+	retries    int
+	t1timer    *time.Timer
+	t2timer    *time.Timer
+	err        error
+}
+
+// newLink creates a new link, using the supplied framer, between the mailbox
+// call sign and the remote address.  Its timers are scaled based on the TNC
+// transmit delay.
+func newLink(f *framer, txdelay int, remote, mailbox, opcall string, log io.Writer) (l *link, err error) {
+	l = &link{f: f, opcall: strings.ToUpper(opcall), log: log, done: make(chan struct{})}
+	if l.local, err = parseAddress(mailbox); err != nil {
+		return nil, err
+	}
+	if l.remote, err = parseAddress(remote); err != nil {
+		return nil, err
+	}
+	// T2, the delay before acknowledging received frames, allows time for
+	// the rest of a multi-frame transmission to arrive.  T1, the delay
+	// before retransmitting an unacknowledged frame, allows time for a full
+	// window of frames to go out and the acknowledgment to come back.
+	frameTime := time.Duration(paclen+20) * 8 * time.Second / airRate
+	l.t2 = time.Duration(txdelay)*time.Millisecond + frameTime
+	l.t1 = 3*time.Second + 2*(time.Duration(txdelay)*time.Millisecond+window*frameTime)
+	l.cond = sync.NewCond(&l.mu)
+	go l.receiver()
+	return l, nil
+}
+
+// connect establishes the link.
+func (l *link) connect() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logf("connecting to %s as %s", l.remote, l.local)
+	l.state, l.retries = stateConnecting, 0
+	l.sendU(ctlSABM, true, true)
+	l.startT1()
+	for l.state == stateConnecting {
+		l.cond.Wait()
+	}
+	if l.state != stateConnected {
+		if l.err == nil {
+			l.err = errors.New("connection failed")
+		}
+		return l.err
+	}
+	l.logf("connected to %s", l.remote)
+	return nil
+}
+
+// Read reads data received over the link.
+func (l *link) Read(p []byte) (n int, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for len(l.rbuf) == 0 && l.err == nil {
+		l.cond.Wait()
+	}
+	if len(l.rbuf) != 0 {
+		n = copy(p, l.rbuf)
+		l.rbuf = l.rbuf[n:]
+		return n, nil
+	}
+	return 0, l.err
+}
+
+// Write queues data to be sent over the link.
+func (l *link) Write(p []byte) (n int, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.err != nil {
+		return 0, l.err
+	}
+	l.sendq = append(l.sendq, p...)
+	l.pump()
+	return len(p), nil
+}
+
+// Close waits for all sent data to be acknowledged, disconnects the link, and
+// closes the underlying TNC connection.
+func (l *link) Close() (err error) {
+	l.mu.Lock()
+	for l.err == nil && (len(l.sendq) != 0 || l.va != l.vs) {
+		l.cond.Wait()
+	}
+	if l.state == stateConnected {
+		l.logf("disconnecting from %s", l.remote)
+		l.state, l.retries = stateDisconnecting, 0
+		l.stopT2()
+		l.sendU(ctlDISC, true, true)
+		l.startT1()
+		for l.state == stateDisconnecting {
+			l.cond.Wait()
+		}
+	}
+	if l.err != nil && l.err != io.EOF {
+		err = l.err
+	}
+	l.mu.Unlock()
+	l.shutdown()
+	return err
+}
+
+// shutdown sends station identification if needed, and closes the underlying
+// TNC connection.
+func (l *link) shutdown() {
+	l.mu.Lock()
+	l.state = stateDisconnected
+	l.stopT1()
+	l.stopT2()
+	if l.err == nil {
+		l.err = io.EOF
+	}
+	l.cond.Broadcast()
+	l.mu.Unlock()
+	if l.opcall != "" && l.opcall != l.local.call {
+		l.send(&frame{dest: address{call: "ID"}, src: l.local, command: true, control: ctlUI, info: []byte(l.opcall)})
+	}
+	l.f.close()
+	<-l.done
+}
+
+// receiver runs in a separate goroutine, reading frames from the TNC and
+// handling them.
+func (l *link) receiver() {
+	defer close(l.done)
+	for {
+		data, err := l.f.readFrame()
+		if err != nil {
+			l.mu.Lock()
+			if l.state != stateDisconnected {
+				l.fail(fmt.Errorf("TNC read: %s", err))
+			}
+			l.mu.Unlock()
+			return
+		}
+		f, err := decodeFrame(data)
+		if err != nil || f.dest != l.local || f.src != l.remote {
+			continue // not for us
+		}
+		l.mu.Lock()
+		l.receive(f)
+		l.mu.Unlock()
+	}
+}
+
+// receive handles a single received frame.  It is called with the lock held.
+func (l *link) receive(f *frame) {
+	pf := f.control&ctlPF != 0
+	switch {
+	case f.control&0x01 == 0: // I frame
+		l.receiveI(f, pf)
+	case f.control&0x03 == 0x01: // S frame
+		l.receiveS(f, pf)
+	default: // U frame
+		l.receiveU(f, pf)
+	}
+}
+
+func (l *link) receiveI(f *frame, poll bool) {
+	if l.state != stateConnected {
+		if l.state == stateDisconnected {
+			l.sendU(ctlDM, poll, false)
+		}
+		return
+	}
+	l.acknowledged(int(f.control >> 5))
+	if ns := int(f.control>>1) & 0x07; ns == l.vr {
+		l.rbuf = append(l.rbuf, f.info...)
+		l.vr = (l.vr + 1) % modulus
+		l.rejSent = false
+		l.cond.Broadcast()
+		if poll {
+			l.sendS(ctlRR, true, false)
+		} else {
+			l.startT2()
+		}
+	} else if !l.rejSent {
+		l.sendS(ctlREJ, poll, false)
+		l.rejSent = true
+	} else if poll {
+		l.sendS(ctlRR, true, false)
+	}
+}
+
+func (l *link) receiveS(f *frame, pf bool) {
+	if l.state != stateConnected && l.state != stateDisconnecting {
+		return
+	}
+	nr := int(f.control >> 5)
+	l.remoteBusy = f.control&0x0F == ctlRNR
+	l.acknowledged(nr)
+	if f.command && pf {
+		l.sendS(ctlRR, true, false)
+	}
+	if f.control&0x0F == ctlREJ || (!f.command && pf) {
+		// The remote station is asking for (or, in answer to our
+		// poll, telling us where to start) retransmission.
+		l.retransmit()
+	}
+	l.pump()
+}
+
+func (l *link) receiveU(f *frame, pf bool) {
+	switch f.control &^ ctlPF {
+	case ctlUA:
+		switch l.state {
+		case stateConnecting:
+			l.state, l.retries = stateConnected, 0
+			l.stopT1()
+			l.cond.Broadcast()
+		case stateDisconnecting:
+			l.state = stateDisconnected
+			l.stopT1()
+			l.cond.Broadcast()
+		}
+	case ctlDM:
+		switch l.state {
+		case stateConnecting:
+			l.fail(fmt.Errorf("connection refused by %s", l.remote))
+		case stateDisconnecting:
+			l.state = stateDisconnected
+			l.stopT1()
+			l.cond.Broadcast()
+		case stateConnected:
+			l.fail(fmt.Errorf("disconnected by %s", l.remote))
+		}
+	case ctlSABM:
+		// The remote station is resetting the link.
+		l.sendU(ctlUA, pf, false)
+		if l.state == stateConnected {
+			l.logf("link reset by %s", l.remote)
+			l.vs, l.va, l.vr = 0, 0, 0
+			l.sent = [modulus][]byte{}
+			l.stopT1()
+		}
+	case ctlDISC:
+		l.sendU(ctlUA, pf, false)
+		if l.state != stateDisconnected {
+			l.logf("disconnected by %s", l.remote)
+			l.state = stateDisconnected
+			if l.err == nil {
+				l.err = io.EOF
+			}
+			l.stopT1()
+			l.stopT2()
+			l.cond.Broadcast()
+		}
+	case ctlFRMR:
+		l.fail(fmt.Errorf("protocol error reported by %s", l.remote))
+	}
+}
+
+// acknowledged handles an N(R) received from the remote station, which
+// acknowledges all frames we sent before that sequence number.
+func (l *link) acknowledged(nr int) {
+	if (nr-l.va+modulus)%modulus > (l.vs-l.va+modulus)%modulus {
+		return // invalid N(R); ignore it
+	}
+	if nr == l.va {
+		return
+	}
+	for l.va != nr {
+		l.sent[l.va] = nil
+		l.va = (l.va + 1) % modulus
+	}
+	l.retries = 0
+	if l.va == l.vs {
+		l.stopT1()
+	} else {
+		l.startT1()
+	}
+	l.pump()
+	l.cond.Broadcast()
+}
+
+// pump sends as much queued data as the window allows.
+func (l *link) pump() {
+	for l.state == stateConnected && !l.remoteBusy && len(l.sendq) != 0 && (l.vs-l.va+modulus)%modulus < window {
+		n := min(len(l.sendq), paclen)
+		info := make([]byte, n)
+		copy(info, l.sendq)
+		l.sendq = l.sendq[n:]
+		l.sent[l.vs] = info
+		l.sendI(l.vs, false)
+		l.vs = (l.vs + 1) % modulus
+		if l.t1timer == nil {
+			l.startT1()
+		}
+	}
+	if l.remoteBusy && len(l.sendq) != 0 && l.t1timer == nil {
+		l.startT1() // so that we'll poll to see if it's still busy
+	}
+}
+
+// retransmit resends all unacknowledged I frames.
+func (l *link) retransmit() {
+	for ns := l.va; ns != l.vs; ns = (ns + 1) % modulus {
+		l.sendI(ns, false)
+	}
+	if l.va != l.vs {
+		l.startT1()
+	}
+}
+
+// timeout is called when T1 expires.
+func (l *link) timeout() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.t1timer = nil
+	if l.retries++; l.retries >= maxRetries {
+		switch l.state {
+		case stateConnecting:
+			l.fail(fmt.Errorf("no response from %s", l.remote))
+		case stateDisconnecting:
+			l.state = stateDisconnected
+			l.cond.Broadcast()
+		case stateConnected:
+			l.fail(fmt.Errorf("link to %s failed: no acknowledgment", l.remote))
+		}
+		return
+	}
+	switch l.state {
+	case stateConnecting:
+		l.sendU(ctlSABM, true, true)
+	case stateDisconnecting:
+		l.sendU(ctlDISC, true, true)
+	case stateConnected:
+		if l.va == l.vs && !(l.remoteBusy && len(l.sendq) != 0) {
+			return
+		}
+		// Poll the remote station to find out what it has received.
+		l.logf("no acknowledgment from %s, retrying", l.remote)
+		l.sendS(ctlRR, true, true)
+	default:
+		return
+	}
+	l.startT1()
+}
+
+// fail shuts down the link with the specified error.  It is called with the
+// lock held.
+func (l *link) fail(err error) {
+	l.logf("%s", err)
+	l.state = stateDisconnected
+	if l.err == nil {
+		l.err = err
+	}
+	l.stopT1()
+	l.stopT2()
+	l.cond.Broadcast()
+}
+
+func (l *link) startT1() {
+	l.stopT1()
+	l.t1timer = time.AfterFunc(l.t1, l.timeout)
+}
+
+func (l *link) stopT1() {
+	if l.t1timer != nil {
+		l.t1timer.Stop()
+		l.t1timer = nil
+	}
+}
+
+func (l *link) startT2() {
+	if l.t2timer == nil {
+		l.t2timer = time.AfterFunc(l.t2, func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			if l.t2timer != nil {
+				l.t2timer = nil
+				if l.state == stateConnected {
+					l.sendS(ctlRR, false, false)
+				}
+			}
+		})
+	}
+}
+
+func (l *link) stopT2() {
+	if l.t2timer != nil {
+		l.t2timer.Stop()
+		l.t2timer = nil
+	}
+}
+
+// sendI sends the I frame with the specified sequence number.  Since it carries
+// our N(R), it also serves as an acknowledgment.
+func (l *link) sendI(ns int, poll bool) {
+	var control = byte(l.vr<<5 | ns<<1)
+	if poll {
+		control |= ctlPF
+	}
+	l.stopT2()
+	l.send(&frame{dest: l.remote, src: l.local, command: true, control: control, info: l.sent[ns]})
+}
+
+// sendS sends a supervisory frame.
+func (l *link) sendS(ctl byte, pf, command bool) {
+	var control = byte(l.vr<<5) | ctl
+	if pf {
+		control |= ctlPF
+	}
+	l.stopT2()
+	l.send(&frame{dest: l.remote, src: l.local, command: command, control: control})
+}
+
+// sendU sends an unnumbered frame.
+func (l *link) sendU(ctl byte, pf, command bool) {
+	if pf {
+		ctl |= ctlPF
+	}
+	l.send(&frame{dest: l.remote, src: l.local, command: command, control: ctl})
+}
+
+// send sends a frame to the TNC.  Write errors will show up as link failures
+// through the retry mechanism, so they are not reported here.
+func (l *link) send(f *frame) {
+	l.f.writeFrame(cmdData, f.encode())
+}
+
+func (l *link) logf(format string, args ...any) {
+	if l.log != nil {
+		fmt.Fprintf(l.log, "[AX.25 %s]\n", fmt.Sprintf(format, args...))
+	}
+}
@@ -0,0 +1,481 @@
+package kiss
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		in   string
+		want address
+		ok   bool
+	}{
+		{"W6XSC", address{"W6XSC", 0}, true},
+		{"w6xsc-1", address{"W6XSC", 1}, true},
+		{"KC6RSC-15", address{"KC6RSC", 15}, true},
+		{"XNDEOC-0", address{"XNDEOC", 0}, true},
+		{"", address{}, false},
+		{"KC6RSCX", address{}, false},
+		{"W6XSC-16", address{}, false},
+		{"W6XSC-", address{}, false},
+		{"W6 XSC", address{}, false},
+	}
+	for _, tt := range tests {
+		got, err := parseAddress(tt.in)
+		if tt.ok && err != nil {
+			t.Errorf("parseAddress(%q) error %s", tt.in, err)
+		} else if !tt.ok && err == nil {
+			t.Errorf("parseAddress(%q) = %v, want error", tt.in, got)
+		} else if got != tt.want {
+			t.Errorf("parseAddress(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAddressEncoding(t *testing.T) {
+	tests := []struct {
+		addr       address
+		cbit, last bool
+		want       []byte
+	}{
+		{address{"W6XSC", 1}, false, false, []byte{'W' << 1, '6' << 1, 'X' << 1, 'S' << 1, 'C' << 1, ' ' << 1, 0x62}},
+		{address{"W6XSC", 1}, true, false, []byte{'W' << 1, '6' << 1, 'X' << 1, 'S' << 1, 'C' << 1, ' ' << 1, 0xE2}},
+		{address{"KC6RSC", 0}, false, true, []byte{'K' << 1, 'C' << 1, '6' << 1, 'R' << 1, 'S' << 1, 'C' << 1, 0x61}},
+		{address{"ID", 15}, true, true, []byte{'I' << 1, 'D' << 1, ' ' << 1, ' ' << 1, ' ' << 1, ' ' << 1, 0xFF}},
+	}
+	for _, tt := range tests {
+		got := tt.addr.encode(nil, tt.cbit, tt.last)
+		if !bytes.Equal(got, tt.want) {
+			t.Errorf("%v.encode(%v, %v) = % X, want % X", tt.addr, tt.cbit, tt.last, got, tt.want)
+		}
+		addr, cbit, last := decodeAddress(got)
+		if addr != tt.addr || cbit != tt.cbit || last != tt.last {
+			t.Errorf("decodeAddress(% X) = %v, %v, %v; want %v, %v, %v", got, addr, cbit, last, tt.addr, tt.cbit, tt.last)
+		}
+	}
+}
+
+func TestFrameEncoding(t *testing.T) {
+	var (
+		bbs = address{"W6XSC", 1}
+		me  = address{"KC6RSC", 0}
+	)
+	tests := []struct {
+		name string
+		f    frame
+		want []byte
+	}{
+		{"SABM", frame{dest: bbs, src: me, command: true, control: ctlSABM | ctlPF},
+			append(append(bbs.encode(nil, true, false), me.encode(nil, false, true)...), ctlSABM|ctlPF)},
+		{"UA", frame{dest: me, src: bbs, command: false, control: ctlUA | ctlPF},
+			append(append(me.encode(nil, false, false), bbs.encode(nil, true, true)...), ctlUA|ctlPF)},
+		{"RR", frame{dest: bbs, src: me, command: false, control: 3<<5 | ctlRR},
+			append(append(bbs.encode(nil, false, false), me.encode(nil, true, true)...), 3<<5|ctlRR)},
+		{"I", frame{dest: bbs, src: me, command: true, control: 2<<5 | 5<<1, info: []byte("hi")},
+			append(append(bbs.encode(nil, true, false), me.encode(nil, false, true)...), 2<<5|5<<1, pidNone, 'h', 'i')},
+		{"UI", frame{dest: address{call: "ID"}, src: me, command: true, control: ctlUI, info: []byte("KC6RSC")},
+			append(append(address{call: "ID"}.encode(nil, true, false), me.encode(nil, false, true)...), append([]byte{ctlUI, pidNone}, "KC6RSC"...)...)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.f.encode()
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("encode = % X, want % X", got, tt.want)
+			}
+			f, err := decodeFrame(got)
+			if err != nil {
+				t.Fatalf("decodeFrame: %s", err)
+			}
+			if f.dest != tt.f.dest || f.src != tt.f.src || f.command != tt.f.command || f.control != tt.f.control || !bytes.Equal(f.info, tt.f.info) {
+				t.Errorf("decodeFrame = %+v, want %+v", f, tt.f)
+			}
+		})
+	}
+}
+
+func TestDecodeFrameDigipeated(t *testing.T) {
+	var (
+		bbs  = address{"W6XSC", 1}
+		me   = address{"KC6RSC", 0}
+		digi = address{"W6ABC", 2}
+		buf  []byte
+	)
+	buf = me.encode(buf, true, false)
+	buf = bbs.encode(buf, false, false)
+	buf = digi.encode(buf, false, true)
+	buf = append(buf, 1<<1, pidNone, 'o', 'k')
+	f, err := decodeFrame(buf)
+	if err != nil {
+		t.Fatalf("decodeFrame: %s", err)
+	}
+	if f.dest != me || f.src != bbs || !f.command || f.control != 1<<1 || string(f.info) != "ok" {
+		t.Errorf("decodeFrame = %+v", f)
+	}
+	if _, err = decodeFrame(buf[:17]); err == nil {
+		t.Error("decodeFrame of truncated digipeater path succeeded")
+	}
+	if _, err = decodeFrame(buf[:10]); err == nil {
+		t.Error("decodeFrame of truncated frame succeeded")
+	}
+}
+
+// The rest of the tests run a link against a simulated BBS on the other end
+// of a net.Pipe.
+
+var (
+	testBBS  = address{"W6XSC", 1}
+	testHost = address{"KC6RSC", 0}
+)
+
+// A testPeer is the BBS end of a link under test.
+type testPeer struct {
+	t      *testing.T
+	conn   net.Conn
+	f      *framer
+	frames chan *frame
+}
+
+// newTestLink returns a link to a test peer.  The link's T1 timer is set to t1,
+// and its T2 timer is set very short so that the tests don't wait for
+// acknowledgments.
+func newTestLink(t *testing.T, t1 time.Duration, opcall string) (*link, *testPeer) {
+	var hostEnd, peerEnd = net.Pipe()
+
+	l, err := newLink(newFramer(hostEnd), 0, testBBS.String(), testHost.String(), opcall, nil)
+	if err != nil {
+		t.Fatalf("newLink: %s", err)
+	}
+	l.mu.Lock()
+	l.t1, l.t2 = t1, 10*time.Millisecond
+	l.mu.Unlock()
+	p := &testPeer{t: t, conn: peerEnd, f: newFramer(peerEnd), frames: make(chan *frame, 64)}
+	// Read frames continuously, so that the link never blocks writing
+	// to the pipe.
+	go func() {
+		defer close(p.frames)
+		for {
+			data, err := p.f.readFrame()
+			if err != nil {
+				return
+			}
+			if f, err := decodeFrame(data); err == nil {
+				p.frames <- f
+			}
+		}
+	}()
+	t.Cleanup(func() { peerEnd.Close() })
+	return l, p
+}
+
+// connectedTestLink returns a test link that has been connected.
+func connectedTestLink(t *testing.T, t1 time.Duration) (*link, *testPeer) {
+	var errch = make(chan error)
+
+	l, p := newTestLink(t, t1, testHost.call)
+	go func() { errch <- l.connect() }()
+	p.expect(ctlSABM|ctlPF, true)
+	p.send(ctlUA|ctlPF, false, nil)
+	if err := <-errch; err != nil {
+		t.Fatalf("connect: %s", err)
+	}
+	return l, p
+}
+
+// expect waits for the next frame from the link, and verifies its control
+// field and command bit.
+func (p *testPeer) expect(control byte, command bool) *frame {
+	p.t.Helper()
+	select {
+	case f, ok := <-p.frames:
+		if !ok {
+			p.t.Fatalf("got EOF, want frame %02X", control)
+		}
+		if f.dest != testBBS || f.src != testHost {
+			p.t.Fatalf("got frame from %s to %s, want from %s to %s", f.src, f.dest, testHost, testBBS)
+		}
+		if f.control != control || f.command != command {
+			p.t.Fatalf("got frame %02X (command %v), want %02X (command %v)", f.control, f.command, control, command)
+		}
+		return f
+	case <-time.After(5 * time.Second):
+		p.t.Fatalf("timed out waiting for frame %02X", control)
+	}
+	return nil
+}
+
+// expectI waits for an I frame with the specified sequence numbers.
+func (p *testPeer) expectI(ns, nr int) *frame {
+	p.t.Helper()
+	return p.expect(iControl(ns, nr), true)
+}
+
+// expectRR waits for an RR response acknowledging everything before nr.  A
+// T2 expiry in the middle of a burst may acknowledge part of it first, so
+// earlier RR responses are skipped.
+func (p *testPeer) expectRR(nr int) {
+	p.t.Helper()
+	for {
+		var f *frame
+		select {
+		case f = <-p.frames:
+		case <-time.After(5 * time.Second):
+			p.t.Fatalf("timed out waiting for RR %d", nr)
+		}
+		if f == nil {
+			p.t.Fatalf("got EOF, want RR %d", nr)
+		}
+		if f.command || f.control&0x0F != ctlRR || f.control&ctlPF != 0 {
+			p.t.Fatalf("got frame %02X (command %v), want RR %d", f.control, f.command, nr)
+		}
+		if int(f.control>>5) == nr {
+			return
+		}
+	}
+}
+
+// expectNone verifies that the link sends nothing for the specified duration.
+func (p *testPeer) expectNone(d time.Duration) {
+	p.t.Helper()
+	select {
+	case f, ok := <-p.frames:
+		if ok {
+			p.t.Fatalf("got unexpected frame %02X", f.control)
+		}
+	case <-time.After(d):
+	}
+}
+
+// send sends a frame to the link.
+func (p *testPeer) send(control byte, command bool, info []byte) {
+	p.t.Helper()
+	f := &frame{dest: testHost, src: testBBS, command: command, control: control, info: info}
+	if err := p.f.writeFrame(cmdData, f.encode()); err != nil {
+		p.t.Fatalf("send: %s", err)
+	}
+}
+
+// iControl returns the control field of an I frame.
+func iControl(ns, nr int) byte {
+	return byte(nr<<5 | ns<<1)
+}
+
+// sControl returns the control field of an S frame.
+func sControl(ctl byte, nr int) byte {
+	return byte(nr<<5) | ctl
+}
+
+// closeTestLink disconnects the link, answering its DISC.
+func closeTestLink(t *testing.T, l *link, p *testPeer) {
+	var errch = make(chan error)
+
+	go func() { errch <- l.Close() }()
+	p.expect(ctlDISC|ctlPF, true)
+	p.send(ctlUA|ctlPF, false, nil)
+	if err := <-errch; err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+}
+
+func TestLinkConnectAndDisconnect(t *testing.T) {
+	l, p := connectedTestLink(t, 2*time.Second)
+	closeTestLink(t, l, p)
+	// The link closes the pipe when it's done.
+	if _, ok := <-p.frames; ok {
+		t.Error("got frame after disconnect")
+	}
+}
+
+func TestLinkConnectRefused(t *testing.T) {
+	var errch = make(chan error)
+
+	l, p := newTestLink(t, 2*time.Second, testHost.call)
+	go func() { errch <- l.connect() }()
+	p.expect(ctlSABM|ctlPF, true)
+	p.send(ctlDM|ctlPF, false, nil)
+	if err := <-errch; err == nil {
+		t.Error("connect succeeded, want error")
+	}
+	l.shutdown()
+}
+
+func TestLinkConnectRetry(t *testing.T) {
+	var errch = make(chan error)
+
+	l, p := newTestLink(t, 200*time.Millisecond, testHost.call)
+	go func() { errch <- l.connect() }()
+	p.expect(ctlSABM|ctlPF, true)
+	// No answer; T1 expires and the SABM is sent again.
+	p.expect(ctlSABM|ctlPF, true)
+	p.send(ctlUA|ctlPF, false, nil)
+	if err := <-errch; err != nil {
+		t.Fatalf("connect: %s", err)
+	}
+	closeTestLink(t, l, p)
+}
+
+func TestLinkStationID(t *testing.T) {
+	var errch = make(chan error)
+
+	l, p := newTestLink(t, 2*time.Second, "KC6AAA")
+	go func() { errch <- l.connect() }()
+	p.expect(ctlSABM|ctlPF, true)
+	p.send(ctlUA|ctlPF, false, nil)
+	if err := <-errch; err != nil {
+		t.Fatalf("connect: %s", err)
+	}
+	go func() { errch <- l.Close() }()
+	p.expect(ctlDISC|ctlPF, true)
+	p.send(ctlUA|ctlPF, false, nil)
+	// The operator's call sign differs from the mailbox call sign, so an
+	// identification frame is sent after disconnecting.
+	select {
+	case f := <-p.frames:
+		if f == nil || f.dest != (address{call: "ID"}) || f.control != ctlUI || string(f.info) != "KC6AAA" {
+			t.Errorf("got %+v, want ID frame", f)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for ID frame")
+	}
+	if err := <-errch; err != nil {
+		t.Fatalf("Close: %s", err)
+	}
+}
+
+func TestLinkSendWindow(t *testing.T) {
+	l, p := connectedTestLink(t, 2*time.Second)
+	data := bytes.Repeat([]byte("0123456789abcdef"), 6*paclen/16)
+	if _, err := l.Write(data); err != nil {
+		t.Fatalf("Write: %s", err)
+	}
+	// Only a window's worth of frames is sent until they are
+	// acknowledged.
+	var got []byte
+	for ns := 0; ns < window; ns++ {
+		got = append(got, p.expectI(ns, 0).info...)
+	}
+	p.expectNone(200 * time.Millisecond)
+	p.send(sControl(ctlRR, window), false, nil)
+	for ns := window; ns < 6; ns++ {
+		got = append(got, p.expectI(ns, 0).info...)
+	}
+	p.send(sControl(ctlRR, 6), false, nil)
+	if !bytes.Equal(got, data) {
+		t.Errorf("sent %d bytes, want %d", len(got), len(data))
+	}
+	closeTestLink(t, l, p)
+}
+
+func TestLinkReceive(t *testing.T) {
+	var buf = make([]byte, 100)
+
+	l, p := connectedTestLink(t, 2*time.Second)
+	p.send(iControl(0, 0), true, []byte("hello, "))
+	p.send(iControl(1, 0), true, []byte("world"))
+	got := ""
+	for len(got) < len("hello, world") {
+		n, err := l.Read(buf)
+		if err != nil {
+			t.Fatalf("Read: %s", err)
+		}
+		got += string(buf[:n])
+	}
+	if got != "hello, world" {
+		t.Errorf("Read %q, want %q", got, "hello, world")
+	}
+	// The frames are acknowledged when T2 expires.
+	p.expectRR(2)
+	// A poll is answered immediately.
+	p.send(iControl(2, 0)|ctlPF, true, []byte("!"))
+	p.expect(sControl(ctlRR, 3)|ctlPF, false)
+	closeTestLink(t, l, p)
+}
+
+func TestLinkReceiveOutOfSequence(t *testing.T) {
+	l, p := connectedTestLink(t, 2*time.Second)
+	p.send(iControl(0, 0), true, []byte("a"))
+	p.expectRR(1)
+	// Frame 1 is lost; frame 2 arrives.  The link rejects it, only once.
+	p.send(iControl(2, 0), true, []byte("c"))
+	p.expect(sControl(ctlREJ, 1), false)
+	p.send(iControl(3, 0), true, []byte("d"))
+	p.expectNone(100 * time.Millisecond)
+	// The retransmissions are accepted.
+	p.send(iControl(1, 0), true, []byte("b"))
+	p.send(iControl(2, 0), true, []byte("c"))
+	p.expectRR(3)
+	var buf = make([]byte, 10)
+	got := ""
+	for len(got) < 3 {
+		n, _ := l.Read(buf)
+		got += string(buf[:n])
+	}
+	if got != "abc" {
+		t.Errorf("Read %q, want %q", got, "abc")
+	}
+	closeTestLink(t, l, p)
+}
+
+func TestLinkREJRetransmit(t *testing.T) {
+	l, p := connectedTestLink(t, 2*time.Second)
+	l.Write(bytes.Repeat([]byte{'x'}, 3*paclen))
+	p.expectI(0, 0)
+	p.expectI(1, 0)
+	p.expectI(2, 0)
+	// Frame 1 was lost.  The REJ acknowledges frame 0 and asks for the
+	// rest to be sent again.
+	p.send(sControl(ctlREJ, 1), false, nil)
+	p.expectI(1, 0)
+	p.expectI(2, 0)
+	p.send(sControl(ctlRR, 3), false, nil)
+	closeTestLink(t, l, p)
+}
+
+func TestLinkT1Recovery(t *testing.T) {
+	l, p := connectedTestLink(t, 100*time.Millisecond)
+	l.Write([]byte("lost"))
+	p.expectI(0, 0)
+	// No acknowledgment arrives, so when T1 expires the link polls.
+	p.expect(sControl(ctlRR, 0)|ctlPF, true)
+	// The answer says frame 0 was not received, so it is sent again.
+	p.send(sControl(ctlRR, 0)|ctlPF, false, nil)
+	if f := p.expectI(0, 0); string(f.info) != "lost" {
+		t.Errorf("retransmitted %q, want %q", f.info, "lost")
+	}
+	p.send(sControl(ctlRR, 1), false, nil)
+	p.expectNone(300 * time.Millisecond)
+	closeTestLink(t, l, p)
+}
+
+func TestLinkT1Failure(t *testing.T) {
+	var buf = make([]byte, 10)
+
+	l, p := connectedTestLink(t, 10*time.Millisecond)
+	l.Write([]byte("x"))
+	p.expectI(0, 0)
+	for i := 1; i < maxRetries; i++ {
+		p.expect(sControl(ctlRR, 0)|ctlPF, true)
+	}
+	// After N2 tries, the link gives up.
+	if _, err := l.Read(buf); err == nil || err == io.EOF {
+		t.Errorf("Read error %v, want link failure", err)
+	}
+	l.shutdown()
+}
+
+func TestLinkRemoteDisconnect(t *testing.T) {
+	var buf = make([]byte, 10)
+
+	l, p := connectedTestLink(t, 2*time.Second)
+	p.send(ctlDISC|ctlPF, true, nil)
+	p.expect(ctlUA|ctlPF, false)
+	if _, err := l.Read(buf); err != io.EOF {
+		t.Errorf("Read error %v, want EOF", err)
+	}
+	if err := l.Close(); err != nil {
+		t.Errorf("Close: %s", err)
+	}
+}
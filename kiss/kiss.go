@@ -0,0 +1,189 @@
+// Package kiss provides a transport for connecting to a JNOS BBS through a TNC
+// that speaks the KISS protocol (e.g., Mobilinkd, NinoTNC, TNC-Pi).  A KISS TNC
+// is just a modem:  it sends and receives raw AX.25 frames and leaves all link
+// management to the host.  So this package implements AX.25 connected mode
+// itself, and hands the resulting byte stream to the JNOS client code.
+package kiss
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/rothskeller/packet/jnos"
+	"go.bug.st/serial"
+)
+
+// KISS framing bytes.
+const (
+	fend  = 0xC0
+	fesc  = 0xDB
+	tfend = 0xDC
+	tfesc = 0xDD
+)
+
+// KISS command codes (low nibble of the type byte).
+const (
+	cmdData    = 0x00
+	cmdTxDelay = 0x01
+	cmdPersist = 0x02
+)
+
+// Default settings, used when the configuration doesn't specify them.
+const (
+	DefaultBaud    = 9600
+	DefaultTxDelay = 300 // milliseconds
+	DefaultPersist = 63
+)
+
+// Connect opens a connection to the BBS at the specified AX.25 address, through
+// the KISS TNC attached to the specified serial port.  baud is the speed of the
+// serial port; txdelay (in milliseconds) and persist (0-255) are passed to the
+// TNC as KISS parameters.  The connection is made from the specified mailbox
+// call sign.  If that is not the same as the operator's FCC call sign, the
+// FCC call sign is sent as an identification frame when the connection is
+// closed.  The log of the connection, including link-level events, is written
+// to log.
+func Connect(portname string, baud, txdelay, persist int, remote, mailbox, opcall string, log io.Writer) (c *jnos.Conn, err error) {
+	var port serial.Port
+
+	if port, err = serial.Open(portname, &serial.Mode{BaudRate: baud}); err != nil {
+		return nil, fmt.Errorf("open %s: %s", portname, err)
+	}
+	return connect(port, txdelay, persist, remote, mailbox, opcall, log)
+}
+
+// ConnectTCP opens a connection to the BBS at the specified AX.25 address,
+// through a soundcard modem program (such as Direwolf) that provides a KISS
+// interface on the specified TCP host:port.  The modem program is responsible
+// for its own transmit timing parameters.  The other arguments are the same as
+// for Connect.
+func ConnectTCP(tncaddr, remote, mailbox, opcall string, log io.Writer) (c *jnos.Conn, err error) {
+	var conn net.Conn
+
+	if conn, err = net.Dial("tcp", tncaddr); err != nil {
+		return nil, fmt.Errorf("connect to %s: %s", tncaddr, err)
+	}
+	return connect(conn, -1, -1, remote, mailbox, opcall, log)
+}
+
+// connect runs the KISS protocol over the supplied byte stream, makes an AX.25
+// connection to the remote station, and starts a JNOS session over it.  If
+// txdelay or persist is negative, the corresponding KISS parameter is not set.
+func connect(rwc io.ReadWriteCloser, txdelay, persist int, remote, mailbox, opcall string, log io.Writer) (c *jnos.Conn, err error) {
+	var (
+		f = newFramer(rwc)
+		l *link
+	)
+	if txdelay >= 0 {
+		if err = f.writeFrame(cmdTxDelay, []byte{byte(min((txdelay+9)/10, 255))}); err != nil {
+			rwc.Close()
+			return nil, fmt.Errorf("set TXDELAY: %s", err)
+		}
+	}
+	if persist >= 0 {
+		if err = f.writeFrame(cmdPersist, []byte{byte(min(persist, 255))}); err != nil {
+			rwc.Close()
+			return nil, fmt.Errorf("set persistence: %s", err)
+		}
+	}
+	if l, err = newLink(f, max(txdelay, 0), remote, mailbox, opcall, log); err != nil {
+		rwc.Close()
+		return nil, err
+	}
+	if err = l.connect(); err != nil {
+		l.shutdown()
+		return nil, err
+	}
+	if c, err = jnos.Connect(l, log); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// A framer reads and writes KISS frames on an underlying byte stream.
+type framer struct {
+	rwc io.ReadWriteCloser
+	r   *bufio.Reader
+	wmu sync.Mutex
+}
+
+func newFramer(rwc io.ReadWriteCloser) *framer {
+	return &framer{rwc: rwc, r: bufio.NewReader(rwc)}
+}
+
+// writeFrame writes a single KISS frame with the specified command code (on
+// TNC port 0) and data.
+func (f *framer) writeFrame(cmd byte, data []byte) (err error) {
+	var buf = make([]byte, 0, len(data)+8)
+
+	buf = append(buf, fend, cmd)
+	for _, b := range data {
+		switch b {
+		case fend:
+			buf = append(buf, fesc, tfend)
+		case fesc:
+			buf = append(buf, fesc, tfesc)
+		default:
+			buf = append(buf, b)
+		}
+	}
+	buf = append(buf, fend)
+	f.wmu.Lock()
+	defer f.wmu.Unlock()
+	_, err = f.rwc.Write(buf)
+	return err
+}
+
+// readFrame reads the next data frame from the TNC.  Frames for other TNC
+// ports, and non-data frames, are skipped.
+func (f *framer) readFrame() (data []byte, err error) {
+	for {
+		var (
+			frame   []byte
+			escaped bool
+			b       byte
+		)
+		// Skip to the start of a frame.
+		for b != fend {
+			if b, err = f.r.ReadByte(); err != nil {
+				return nil, err
+			}
+		}
+		// Read to the end of the frame.
+		for {
+			if b, err = f.r.ReadByte(); err != nil {
+				return nil, err
+			}
+			if b == fend {
+				break
+			}
+			switch {
+			case escaped && b == tfend:
+				frame = append(frame, fend)
+			case escaped && b == tfesc:
+				frame = append(frame, fesc)
+			case b == fesc:
+				escaped = true
+				continue
+			default:
+				frame = append(frame, b)
+			}
+			escaped = false
+		}
+		// The closing FEND can also open the next frame.
+		f.r.UnreadByte()
+		// Back-to-back FENDs delimit an empty frame, which we ignore.
+		// So are frames that aren't data frames for port 0.
+		if len(frame) >= 2 && frame[0] == cmdData {
+			return frame[1:], nil
+		}
+	}
+}
+
+func (f *framer) close() error {
+	return f.rwc.Close()
+}
@@ -0,0 +1,249 @@
+package kiss
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bufferRWC is an io.ReadWriteCloser that reads from one buffer and writes to
+// another, for testing the framer.
+type bufferRWC struct {
+	r *bytes.Reader
+	w bytes.Buffer
+}
+
+func (b *bufferRWC) Read(p []byte) (int, error)  { return b.r.Read(p) }
+func (b *bufferRWC) Write(p []byte) (int, error) { return b.w.Write(p) }
+func (b *bufferRWC) Close() error                { return nil }
+
+func TestWriteFrame(t *testing.T) {
+	tests := []struct {
+		name string
+		cmd  byte
+		data []byte
+		want []byte
+	}{
+		{"empty", cmdData, nil, []byte{fend, cmdData, fend}},
+		{"plain", cmdData, []byte("abc"), []byte{fend, cmdData, 'a', 'b', 'c', fend}},
+		{"FEND", cmdData, []byte{'a', fend, 'b'}, []byte{fend, cmdData, 'a', fesc, tfend, 'b', fend}},
+		{"FESC", cmdData, []byte{fesc}, []byte{fend, cmdData, fesc, tfesc, fend}},
+		{"FESC FEND", cmdData, []byte{fesc, fend}, []byte{fend, cmdData, fesc, tfesc, fesc, tfend, fend}},
+		{"TFEND TFESC", cmdData, []byte{tfend, tfesc}, []byte{fend, cmdData, tfend, tfesc, fend}},
+		{"TXDELAY", cmdTxDelay, []byte{30}, []byte{fend, cmdTxDelay, 30, fend}},
+		{"persistence", cmdPersist, []byte{63}, []byte{fend, cmdPersist, 63, fend}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b = &bufferRWC{r: bytes.NewReader(nil)}
+
+			if err := newFramer(b).writeFrame(tt.cmd, tt.data); err != nil {
+				t.Fatalf("writeFrame: %s", err)
+			}
+			if got := b.w.Bytes(); !bytes.Equal(got, tt.want) {
+				t.Errorf("writeFrame = % X, want % X", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	tests := []struct {
+		name  string
+		input []byte
+		want  [][]byte
+	}{
+		{"plain", []byte{fend, cmdData, 'a', 'b', fend}, [][]byte{[]byte("ab")}},
+		{"escapes", []byte{fend, cmdData, fesc, tfend, 'x', fesc, tfesc, fend}, [][]byte{{fend, 'x', fesc}}},
+		{"bare TFEND TFESC", []byte{fend, cmdData, tfend, tfesc, fend}, [][]byte{{tfend, tfesc}}},
+		{"leading noise", []byte{'x', 'y', fend, cmdData, 'a', fend}, [][]byte{[]byte("a")}},
+		{"shared FEND", []byte{fend, cmdData, 'a', fend, cmdData, 'b', fend}, [][]byte{[]byte("a"), []byte("b")}},
+		{"back-to-back FENDs", []byte{fend, fend, fend, cmdData, 'a', fend, fend}, [][]byte{[]byte("a")}},
+		{"no data", []byte{fend, cmdData, fend, cmdData, 'a', fend}, [][]byte{[]byte("a")}},
+		{"non-data frame", []byte{fend, cmdTxDelay, 30, fend, cmdData, 'a', fend}, [][]byte{[]byte("a")}},
+		{"other TNC port", []byte{fend, 0x10, 'x', fend, cmdData, 'a', fend}, [][]byte{[]byte("a")}},
+		{"unterminated", []byte{fend, cmdData, 'a', fend, cmdData, 'b'}, [][]byte{[]byte("a")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var (
+				f   = newFramer(&bufferRWC{r: bytes.NewReader(tt.input)})
+				got [][]byte
+			)
+			for {
+				data, err := f.readFrame()
+				if err == io.EOF {
+					break
+				} else if err != nil {
+					t.Fatalf("readFrame: %s", err)
+				}
+				got = append(got, data)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("readFrame returned %d frames (% X), want %d", len(got), got, len(tt.want))
+			}
+			for i := range got {
+				if !bytes.Equal(got[i], tt.want[i]) {
+					t.Errorf("frame %d = % X, want % X", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestFrameRoundTrip(t *testing.T) {
+	var (
+		data = []byte{0, fend, fesc, tfend, tfesc, 0xFF, fend, fend}
+		b    = &bufferRWC{r: bytes.NewReader(nil)}
+	)
+	if err := newFramer(b).writeFrame(cmdData, data); err != nil {
+		t.Fatalf("writeFrame: %s", err)
+	}
+	got, err := newFramer(&bufferRWC{r: bytes.NewReader(b.w.Bytes())}).readFrame()
+	if err != nil {
+		t.Fatalf("readFrame: %s", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("round trip = % X, want % X", got, data)
+	}
+}
+
+// testTNC is a stand-in for a soundcard modem's KISS TCP interface, with a BBS
+// on the other end of the radio link.  It answers the host's frames the way
+// a JNOS BBS would, and reports what it saw when the host disconnects.
+type testTNC struct {
+	f        *framer
+	vs, vr   int
+	sabm     bool
+	bye      bool
+	id       string
+	received string
+}
+
+func (tnc *testTNC) serve(conn net.Conn) error {
+	tnc.f = newFramer(conn)
+	defer conn.Close()
+	for {
+		data, err := tnc.f.readFrame()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		fr, err := decodeFrame(data)
+		if err != nil {
+			return err
+		}
+		if fr.dest == (address{call: "ID"}) && fr.control == ctlUI {
+			tnc.id = string(fr.info)
+			continue
+		}
+		if fr.dest != testBBS || fr.src != testHost {
+			return fmt.Errorf("got frame from %s to %s", fr.src, fr.dest)
+		}
+		switch {
+		case fr.control == ctlSABM|ctlPF && fr.command:
+			tnc.sabm = true
+			tnc.send(ctlUA|ctlPF, false, nil)
+			tnc.sendText("[JNOS-2.0-B2FHIM$]\nArea: kc6rsc Current msg# 0.\n?,A,B,H,K,L,R,S >\n")
+		case fr.control&0x01 == 0: // I frame
+			if ns := int(fr.control>>1) & 7; ns != tnc.vr {
+				return fmt.Errorf("got I frame %d, want %d", ns, tnc.vr)
+			}
+			tnc.vr = (tnc.vr + 1) % modulus
+			tnc.send(byte(tnc.vr<<5)|ctlRR, false, nil)
+			tnc.received += string(fr.info)
+			for {
+				idx := strings.IndexAny(tnc.received, "\r\n")
+				if idx < 0 {
+					break
+				}
+				line := tnc.received[:idx]
+				tnc.received = tnc.received[idx+1:]
+				if strings.EqualFold(line, "B") {
+					tnc.bye = true
+					tnc.sendText("Thank you kc6rsc, for calling JNOS.\n")
+					tnc.send(ctlDISC|ctlPF, true, nil)
+				} else {
+					tnc.sendText("Area: kc6rsc Current msg# 0.\n?,A,B,H,K,L,R,S >\n")
+				}
+			}
+		case fr.control&0x03 == 0x01: // S frame
+			if fr.command && fr.control&ctlPF != 0 {
+				tnc.send(byte(tnc.vr<<5)|ctlRR|ctlPF, false, nil)
+			}
+		case fr.control&^ctlPF == ctlDISC:
+			tnc.send(ctlUA|(fr.control&ctlPF), false, nil)
+		}
+	}
+}
+
+func (tnc *testTNC) sendText(s string) {
+	tnc.send(byte(tnc.vr<<5|tnc.vs<<1), true, []byte(s))
+	tnc.vs = (tnc.vs + 1) % modulus
+}
+
+func (tnc *testTNC) send(control byte, command bool, info []byte) {
+	f := &frame{dest: testHost, src: testBBS, command: command, control: control, info: info}
+	tnc.f.writeFrame(cmdData, f.encode())
+}
+
+func TestConnectTCP(t *testing.T) {
+	var (
+		tnc   testTNC
+		errch = make(chan error, 1)
+	)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			errch <- err
+			return
+		}
+		errch <- tnc.serve(conn)
+	}()
+	c, err := ConnectTCP(l.Addr().String(), testBBS.String(), testHost.String(), "KC6AAA", nil)
+	if err != nil {
+		t.Fatalf("ConnectTCP: %s", err)
+	}
+	if err = c.Close(); err != nil {
+		t.Errorf("Close: %s", err)
+	}
+	select {
+	case err = <-errch:
+		if err != nil {
+			t.Fatalf("TNC: %s", err)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("timed out waiting for disconnect")
+	}
+	if !tnc.sabm {
+		t.Error("no SABM received")
+	}
+	if !tnc.bye {
+		t.Error("no B command received")
+	}
+	if tnc.id != "KC6AAA" {
+		t.Errorf("ID frame %q, want %q", tnc.id, "KC6AAA")
+	}
+}
+
+func TestConnectTCPRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	if _, err = ConnectTCP(addr, testBBS.String(), testHost.String(), testHost.call, nil); err == nil {
+		t.Error("ConnectTCP succeeded with no TNC listening")
+	}
+}
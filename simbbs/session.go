@@ -0,0 +1,422 @@
+package simbbs
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/mail"
+	"strconv"
+	"strings"
+)
+
+// Telnet protocol bytes that may appear in the input stream.
+const (
+	telnetIAC  = 255
+	telnetWILL = 251
+	telnetDONT = 254
+	telnetSB   = 250
+	telnetSE   = 240
+)
+
+// sid is the system identifier sent by the simulated BBS after login.
+const sid = "[JNOS-2.0-B2FHIM$]"
+
+// A session is a single user connection to the simulated BBS.
+type session struct {
+	s       *Server
+	conn    net.Conn
+	r       *bufio.Reader
+	w       *bufio.Writer
+	user    string
+	area    string
+	msgs    []*bbsMessage
+	killed  map[*bbsMessage]bool
+	current int
+}
+
+// serveSession handles a single telnet connection.
+func (s *Server) serveSession(conn net.Conn) {
+	var ss = session{s: s, conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+
+	defer conn.Close()
+	s.logf("[simbbs: connection from %s]", conn.RemoteAddr())
+	if !ss.login() {
+		s.logf("[simbbs: %s disconnected]", conn.RemoteAddr())
+		return
+	}
+	ss.setArea(ss.user)
+	ss.printf("%s\n", sid)
+	ss.printf("You have %d messages.\n", len(ss.msgs))
+	for {
+		ss.prompt()
+		line, ok := ss.readLine()
+		if !ok {
+			break
+		}
+		if !ss.command(line) {
+			break
+		}
+	}
+	ss.leaveArea()
+	ss.w.Flush()
+	s.logf("[simbbs: %s logged out]", ss.user)
+}
+
+// login prompts for and reads the user's call sign and password.  Any password
+// is accepted.  It returns false if the connection was dropped.
+func (ss *session) login() bool {
+	ss.printf("JNOS (%s)\n\nlogin: ", strings.ToLower(ss.s.call))
+	for ss.user == "" {
+		line, ok := ss.readLine()
+		if !ok {
+			return false
+		}
+		if ss.user = strings.ToLower(strings.TrimSpace(line)); ss.user == "" {
+			ss.printf("login: ")
+		}
+	}
+	ss.printf("Password: ")
+	if _, ok := ss.readLine(); !ok {
+		return false
+	}
+	ss.s.logf("[simbbs: %s logged in]", ss.user)
+	return true
+}
+
+// prompt sends the command prompt.
+func (ss *session) prompt() {
+	ss.printf("Area: %s Current msg# %d.\n?,A,B,H,K,L,R,S >\n", ss.area, ss.current)
+}
+
+// command executes a single command line.  It returns false if the session
+// should end.
+func (ss *session) command(line string) bool {
+	var words = strings.Fields(line)
+
+	if len(words) == 0 {
+		return true
+	}
+	switch cmd := strings.ToLower(words[0]); cmd {
+	case "?", "h", "help":
+		ss.printf("A [area]       - list areas, or change to area\n")
+		ss.printf("B              - log off\n")
+		ss.printf("K n [n...]     - kill messages\n")
+		ss.printf("L, LA          - list messages\n")
+		ss.printf("L> name        - list messages addressed to name\n")
+		ss.printf("R n [n...]     - read messages\n")
+		ss.printf("SP addr [addr...] - send private message\n")
+		ss.printf("SB addr        - send bulletin\n")
+	case "a", "area":
+		ss.cmdArea(words[1:])
+	case "b", "bye":
+		ss.printf("Thank you %s, for calling JNOS.\n", ss.user)
+		return false
+	case "k", "kill":
+		ss.cmdKill(words[1:])
+	case "l", "la", "list":
+		ss.cmdList("")
+	case "l>":
+		if len(words) != 2 {
+			ss.printf("Usage: L> name\n")
+		} else {
+			ss.cmdList(words[1])
+		}
+	case "r", "read":
+		ss.cmdRead(words[1:])
+	case "s", "sp", "send":
+		return ss.cmdSend(words[1:], false)
+	case "sb":
+		return ss.cmdSend(words[1:], true)
+	default:
+		if strings.HasPrefix(cmd, "l>") {
+			ss.cmdList(cmd[2:])
+		} else {
+			ss.printf("Invalid command.\n")
+		}
+	}
+	return true
+}
+
+// cmdArea handles the "a" command.
+func (ss *session) cmdArea(args []string) {
+	if len(args) == 0 {
+		ss.printf("Current area: %s\nAvailable areas:\n", ss.area)
+		for _, name := range ss.s.areaNames() {
+			ss.printf("  %s\n", name)
+		}
+		return
+	}
+	ss.leaveArea()
+	ss.setArea(strings.ToLower(args[0]))
+	ss.printf("Area: %s (%d msgs)\n", ss.area, len(ss.msgs))
+}
+
+// setArea makes the named area the current one.
+func (ss *session) setArea(name string) {
+	ss.area = name
+	ss.msgs = ss.s.snapshot(name)
+	ss.killed = make(map[*bbsMessage]bool)
+	ss.current = 0
+}
+
+// leaveArea removes the messages killed in the current area.  As on a real
+// JNOS BBS, killed messages keep their numbers until the user leaves the area.
+func (ss *session) leaveArea() {
+	if len(ss.killed) != 0 {
+		ss.s.remove(ss.area, ss.killed)
+	}
+}
+
+// cmdKill handles the "k" command.
+func (ss *session) cmdKill(args []string) {
+	if len(args) == 0 {
+		ss.printf("Usage: K n [n...]\n")
+		return
+	}
+	if ss.area != ss.user {
+		ss.printf("Permission denied.\n")
+		return
+	}
+	for _, arg := range args {
+		if m, num := ss.message(arg); m != nil {
+			ss.killed[m] = true
+			ss.printf("Msg %d Killed.\n", num)
+		}
+	}
+}
+
+// cmdList handles the "l", "la", and "l>" commands.
+func (ss *session) cmdList(to string) {
+	var (
+		count, unread int
+		read          = make([]bool, len(ss.msgs))
+	)
+	// Other sessions can mark messages read, so take a copy of the flags.
+	ss.s.mu.Lock()
+	for i, m := range ss.msgs {
+		read[i] = m.read
+	}
+	ss.s.mu.Unlock()
+	for i, m := range ss.msgs {
+		if !ss.killed[m] && matchTo(m.to, to) {
+			count++
+			if !read[i] {
+				unread++
+			}
+		}
+	}
+	ss.printf("Mail area: %s\n", ss.area)
+	if count == 0 {
+		ss.printf("No messages.\n")
+		return
+	}
+	ss.printf("%d messages  -  %d new\n\n", count, unread)
+	ss.printf("St.  #  TO            FROM     DATE   SIZE SUBJECT\n")
+	for i, m := range ss.msgs {
+		if ss.killed[m] || !matchTo(m.to, to) {
+			continue
+		}
+		cur, status := ' ', 'N'
+		if i+1 == ss.current {
+			cur = '>'
+		}
+		if read[i] {
+			status = 'Y'
+		}
+		// JNOS shows the first 35 characters of the subject.
+		ss.printf("%c %c %3d %-13.13s %-8.8s %-6s %4d %.35s\n", cur, status, i+1,
+			m.to, addrUser(m.from), m.date.Format("Jan _2"), len(m.body), m.subject)
+	}
+}
+
+// cmdRead handles the "r" command.
+func (ss *session) cmdRead(args []string) {
+	if len(args) == 0 {
+		ss.printf("Usage: R n [n...]\n")
+		return
+	}
+	for _, arg := range args {
+		m, num := ss.message(arg)
+		if m == nil {
+			continue
+		}
+		ss.printf("Message #%d \n", num)
+		ss.printf("Date: %s\n", m.date.Format("Mon, 02 Jan 2006 15:04:05 -0700"))
+		ss.printf("Message-ID: %s\n", m.msgid)
+		ss.printf("From: %s\n", m.from)
+		ss.printf("To: %s\n", m.to)
+		ss.printf("Subject: %s\n\n", m.subject)
+		ss.printf("%s", m.body)
+		if !strings.HasSuffix(m.body, "\n") {
+			ss.printf("\n")
+		}
+		ss.s.mu.Lock()
+		m.read = true
+		ss.s.mu.Unlock()
+		ss.current = num
+	}
+}
+
+// message returns the message with the specified number in the current area.
+// It reports an error to the user and returns nil if there is no such message.
+func (ss *session) message(arg string) (m *bbsMessage, num int) {
+	var err error
+
+	if num, err = strconv.Atoi(arg); err != nil || num < 1 || num > len(ss.msgs) || ss.killed[ss.msgs[num-1]] {
+		ss.printf("Invalid Msg# %s\n", arg)
+		return nil, 0
+	}
+	return ss.msgs[num-1], num
+}
+
+// cmdSend handles the "sp" and "sb" commands.  It returns false if the
+// connection was dropped.
+func (ss *session) cmdSend(args []string, bulletin bool) bool {
+	var (
+		to      []string
+		subject string
+		body    strings.Builder
+		ok      bool
+	)
+	for i := 0; i < len(args); i++ {
+		switch {
+		case args[i] == "<": // "< from"; ignored
+			i++
+		case strings.HasPrefix(args[i], "<"), strings.HasPrefix(args[i], "$"):
+			// "<from" or "$bid"; ignored
+		default:
+			to = append(to, args[i])
+		}
+	}
+	if len(to) == 0 || (bulletin && len(to) != 1) {
+		ss.printf("Usage: SP addr [addr...], or SB addr\n")
+		return true
+	}
+	ss.printf("Subject: ")
+	if subject, ok = ss.readLine(); !ok {
+		return false
+	}
+	ss.printf("Enter message.  End with /EX or ^Z in first column (^A aborts):\n")
+	for {
+		line, ok := ss.readLine()
+		if !ok {
+			return false
+		}
+		if strings.EqualFold(strings.TrimSpace(line), "/EX") || strings.HasPrefix(line, "\x1A") {
+			break
+		}
+		if strings.HasPrefix(line, "\x01") {
+			ss.printf("Msg aborted\n")
+			return true
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	from := fmt.Sprintf("%s@%s.ampr.org", ss.user, strings.ToLower(ss.s.call))
+	for _, addr := range to {
+		ss.s.post(deliveryArea(addr, bulletin), from, addr, subject, body.String())
+	}
+	ss.printf("Msg queued\n")
+	return true
+}
+
+// deliveryArea returns the name of the area to which a message sent to the
+// specified address is delivered.  All private mail is delivered locally,
+// regardless of the host part of the address, so that a drill can be run with
+// every station using the same simulated BBS.  Bulletins addressed to a
+// distribution (e.g. "xscevent@allxsc") go to the area named by the
+// distribution; other bulletins go to the area named by the address.
+func deliveryArea(addr string, bulletin bool) string {
+	addr = strings.ToLower(addr)
+	user, host, _ := strings.Cut(addr, "@")
+	if bulletin && strings.HasPrefix(host, "all") {
+		host, _, _ = strings.Cut(host, ".")
+		return host
+	}
+	return user
+}
+
+// matchTo returns whether the To: address of a message matches the name given
+// to an "l>" command.  An empty name matches all messages.
+func matchTo(to, name string) bool {
+	return name == "" || strings.EqualFold(addrUser(to), name)
+}
+
+// addrUser returns the user part of an address.
+func addrUser(addr string) string {
+	if a, err := mail.ParseAddress(addr); err == nil {
+		addr = a.Address
+	}
+	user, _, _ := strings.Cut(addr, "@")
+	return user
+}
+
+// readLine reads a line of input from the user, stripping any telnet protocol
+// commands and the line ending.  It returns false if the connection was
+// dropped.
+func (ss *session) readLine() (line string, ok bool) {
+	var buf []byte
+
+	ss.w.Flush()
+	for {
+		b, err := ss.r.ReadByte()
+		if err != nil {
+			return "", false
+		}
+		switch {
+		case b == telnetIAC:
+			ss.skipTelnetCommand()
+		case b == '\n':
+			line = strings.TrimSuffix(string(buf), "\r")
+			ss.s.logf("%s> %s", ss.logName(), line)
+			return line, true
+		case b == 0:
+			// ignore NUL after CR
+		default:
+			buf = append(buf, b)
+		}
+	}
+}
+
+// skipTelnetCommand skips over a telnet protocol command following an IAC.
+func (ss *session) skipTelnetCommand() {
+	b, err := ss.r.ReadByte()
+	if err != nil {
+		return
+	}
+	switch {
+	case b >= telnetWILL && b <= telnetDONT:
+		ss.r.ReadByte() // option code
+	case b == telnetSB:
+		for {
+			if b, err = ss.r.ReadByte(); err != nil {
+				return
+			}
+			if b == telnetIAC {
+				if b, err = ss.r.ReadByte(); err != nil || b == telnetSE {
+					return
+				}
+			}
+		}
+	}
+}
+
+// printf sends formatted output to the user, with telnet line endings.
+func (ss *session) printf(format string, args ...any) {
+	var text = fmt.Sprintf(format, args...)
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if line != "" {
+			ss.s.logf("%s< %s", ss.logName(), strings.TrimSuffix(line, "\n"))
+		}
+	}
+	ss.w.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+}
+
+// logName returns the name identifying this session in the log.
+func (ss *session) logName() string {
+	if ss.user != "" {
+		return ss.user
+	}
+	return ss.conn.RemoteAddr().String()
+}
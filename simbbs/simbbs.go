@@ -0,0 +1,237 @@
+// Package simbbs implements a simulated JNOS BBS, reachable over telnet.  It is
+// intended for tabletop drills and for exercising the "connect" command without
+// a live BBS.  It implements only the subset of the JNOS mailbox commands that
+// the packet software uses:  login, sp/sb, r, k, l/la, and a (area switching).
+//
+// The simulated BBS loads its mailboxes from a directory.  Each subdirectory of
+// that directory is a mail area, named by the subdirectory name.  Each regular
+// file in a subdirectory is an RFC-5322 message in that area; they are numbered
+// in filename order.  Messages sent to the simulated BBS, and kills of
+// messages, are kept in memory only; the directory is never changed.
+package simbbs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Server is a simulated JNOS BBS.
+type Server struct {
+	call   string
+	log    io.Writer
+	logmu  sync.Mutex
+	mu     sync.Mutex
+	areas  map[string]*area
+	nextID int
+}
+
+// An area is a mail area on the BBS:  either a user's mailbox or a bulletin
+// area.
+type area struct {
+	name string
+	msgs []*bbsMessage
+}
+
+// A bbsMessage is a single message stored on the BBS.
+type bbsMessage struct {
+	date    time.Time
+	msgid   string
+	from    string
+	to      string
+	subject string
+	body    string
+	read    bool
+}
+
+// New creates a new simulated BBS with the specified call sign, loading its
+// mailboxes from the specified directory.  A log of all sessions is written to
+// log.
+func New(call, dir string, log io.Writer) (s *Server, err error) {
+	var entries []os.DirEntry
+
+	s = &Server{call: strings.ToUpper(call), log: log, areas: make(map[string]*area), nextID: 1000}
+	if entries, err = os.ReadDir(dir); err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if err = s.loadArea(strings.ToLower(e.Name()), filepath.Join(dir, e.Name())); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// loadArea loads the messages in a single mail area.
+func (s *Server) loadArea(name, dir string) (err error) {
+	var (
+		entries []os.DirEntry
+		a       = &area{name: name}
+	)
+	if entries, err = os.ReadDir(dir); err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	for _, e := range entries {
+		var m *bbsMessage
+
+		if !e.Type().IsRegular() || strings.HasPrefix(e.Name(), ".") {
+			continue
+		}
+		if m, err = s.loadMessage(filepath.Join(dir, e.Name())); err != nil {
+			return err
+		}
+		a.msgs = append(a.msgs, m)
+	}
+	s.areas[name] = a
+	return nil
+}
+
+// loadMessage loads a single message from an RFC-5322 file.
+func (s *Server) loadMessage(filename string) (m *bbsMessage, err error) {
+	var (
+		fh   *os.File
+		info os.FileInfo
+		mm   *mail.Message
+		body []byte
+	)
+	if fh, err = os.Open(filename); err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+	if mm, err = mail.ReadMessage(fh); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	if body, err = io.ReadAll(mm.Body); err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	m = &bbsMessage{
+		msgid:   mm.Header.Get("Message-ID"),
+		from:    mm.Header.Get("From"),
+		to:      mm.Header.Get("To"),
+		subject: mm.Header.Get("Subject"),
+		body:    strings.ReplaceAll(string(body), "\r\n", "\n"),
+	}
+	if m.date, err = mm.Header.Date(); err != nil {
+		if info, err = fh.Stat(); err != nil {
+			return nil, err
+		}
+		m.date = info.ModTime()
+	}
+	if m.msgid == "" {
+		m.msgid = s.newMessageID()
+	}
+	return m, nil
+}
+
+// newMessageID returns a new, unique message ID.
+func (s *Server) newMessageID() string {
+	s.nextID++
+	return fmt.Sprintf("<%d_%s@%s.ampr.org>", s.nextID, strings.ToLower(s.call), strings.ToLower(s.call))
+}
+
+// ListenAndServe listens for telnet connections on the specified TCP address
+// and serves them.  It does not return unless the listener fails.
+func (s *Server) ListenAndServe(addr string) (err error) {
+	var l net.Listener
+
+	if l, err = net.Listen("tcp", addr); err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve serves telnet connections accepted from the specified listener.  It
+// does not return until the listener is closed or fails.
+func (s *Server) Serve(l net.Listener) error {
+	for {
+		conn, err := l.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		go s.serveSession(conn)
+	}
+}
+
+// post adds a message to the specified area, creating it if needed.
+func (s *Server) post(areaname, from, to, subject, body string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.areas[areaname]
+	if a == nil {
+		a = &area{name: areaname}
+		s.areas[areaname] = a
+	}
+	a.msgs = append(a.msgs, &bbsMessage{
+		date:    time.Now(),
+		msgid:   s.newMessageID(),
+		from:    from,
+		to:      to,
+		subject: subject,
+		body:    body,
+	})
+}
+
+// snapshot returns a copy of the list of messages in the specified area.  The
+// copy keeps message numbers stable for the remainder of a session, as they
+// are on a real JNOS BBS.
+func (s *Server) snapshot(areaname string) []*bbsMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if a := s.areas[areaname]; a != nil {
+		return append([]*bbsMessage(nil), a.msgs...)
+	}
+	return nil
+}
+
+// areaNames returns the sorted list of area names.
+func (s *Server) areaNames() (names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range s.areas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// remove removes the specified messages from the specified area.
+func (s *Server) remove(areaname string, killed map[*bbsMessage]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a := s.areas[areaname]
+	if a == nil {
+		return
+	}
+	j := 0
+	for _, m := range a.msgs {
+		if !killed[m] {
+			a.msgs[j] = m
+			j++
+		}
+	}
+	a.msgs = a.msgs[:j]
+}
+
+// logf writes a line to the session log.
+func (s *Server) logf(format string, args ...any) {
+	if s.log == nil {
+		return
+	}
+	s.logmu.Lock()
+	defer s.logmu.Unlock()
+	fmt.Fprintf(s.log, format+"\n", args...)
+}
@@ -0,0 +1,215 @@
+package simbbs
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newServer creates a simulated BBS with mailboxes loaded from the supplied
+// files (keyed by "area/filename").
+func newServer(t *testing.T, files map[string]string) *Server {
+	var dir = t.TempDir()
+
+	for name, contents := range files {
+		fn := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(fn), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fn, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s, err := New("W6XSC", dir, nil)
+	if err != nil {
+		t.Fatalf("New: %s", err)
+	}
+	return s
+}
+
+// startServer starts a simulated BBS with mailboxes loaded from the supplied
+// files, and returns its address.
+func startServer(t *testing.T, files map[string]string) string {
+	s := newServer(t, files)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { l.Close() })
+	return l.Addr().String()
+}
+
+// A testClient is a telnet session with the simulated BBS.
+type testClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// login opens a session with the simulated BBS as the specified user.
+func login(t *testing.T, addr, user string) *testClient {
+	t.Helper()
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	c := &testClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+	c.readUntil("login: ")
+	c.send(user)
+	c.readUntil("Password: ")
+	c.send("anything")
+	c.readUntil("S >\r\n")
+	return c
+}
+
+// readUntil reads output from the BBS until it ends with the specified string,
+// and returns it.
+func (c *testClient) readUntil(end string) string {
+	c.t.Helper()
+	var out []byte
+
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for !strings.HasSuffix(string(out), end) {
+		b, err := c.r.ReadByte()
+		if err != nil {
+			c.t.Fatalf("read (waiting for %q after %q): %s", end, out, err)
+		}
+		out = append(out, b)
+	}
+	return string(out)
+}
+
+func (c *testClient) send(line string) {
+	c.t.Helper()
+	if _, err := c.conn.Write([]byte(line + "\r\n")); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// command sends a command and returns its output, up to the next prompt.
+func (c *testClient) command(line string) string {
+	c.t.Helper()
+	c.send(line)
+	return c.readUntil("S >\r\n")
+}
+
+// bye ends the session.
+func (c *testClient) bye() {
+	c.t.Helper()
+	c.send("B")
+	c.readUntil("for calling JNOS.\r\n")
+}
+
+func TestSendReadKill(t *testing.T) {
+	addr := startServer(t, nil)
+	a := login(t, addr, "kc6aaa")
+	a.send("SP kc6bbb@w6xsc.ampr.org")
+	a.readUntil("Subject: ")
+	a.send("AAA-101P_R_Hello")
+	a.readUntil("(^A aborts):\r\n")
+	a.send("Hello there.")
+	if out := a.command("/EX"); !strings.Contains(out, "Msg queued") {
+		t.Errorf("send: %q", out)
+	}
+	a.bye()
+
+	b := login(t, addr, "kc6bbb")
+	if out := b.command("L"); !strings.Contains(out, "1 messages  -  1 new") || !strings.Contains(out, "kc6aaa") || !strings.Contains(out, "AAA-101P_R_Hello") {
+		t.Errorf("list: %q", out)
+	}
+	out := b.command("R 1")
+	if !strings.Contains(out, "From: kc6aaa@w6xsc.ampr.org\r\n") || !strings.Contains(out, "Subject: AAA-101P_R_Hello\r\n\r\nHello there.\r\n") {
+		t.Errorf("read: %q", out)
+	}
+	if out = b.command("L"); !strings.Contains(out, "1 messages  -  0 new") {
+		t.Errorf("list after read: %q", out)
+	}
+	if out = b.command("K 1"); !strings.Contains(out, "Msg 1 Killed.") {
+		t.Errorf("kill: %q", out)
+	}
+	b.bye()
+
+	b = login(t, addr, "kc6bbb")
+	if out = b.command("L"); !strings.Contains(out, "No messages.") {
+		t.Errorf("list after kill: %q", out)
+	}
+}
+
+func TestBulletinArea(t *testing.T) {
+	addr := startServer(t, nil)
+	a := login(t, addr, "kc6aaa")
+	a.send("SB xscevent@allxsc")
+	a.readUntil("Subject: ")
+	a.send("AAA-101P_R_Drill update")
+	a.readUntil("(^A aborts):\r\n")
+	a.command("/EX")
+	// Bulletins to a distribution go to the area named by the distribution.
+	if out := a.command("A allxsc"); !strings.Contains(out, "Area: allxsc (1 msgs)") {
+		t.Errorf("area: %q", out)
+	}
+	if out := a.command("L> xscevent"); !strings.Contains(out, "AAA-101P_R_Drill update") {
+		t.Errorf("list: %q", out)
+	}
+	if out := a.command("K 1"); !strings.Contains(out, "Permission denied.") {
+		t.Errorf("kill in bulletin area: %q", out)
+	}
+}
+
+func TestListSubjectWidth(t *testing.T) {
+	const subject = "XYZ-001P_R_Exercise starts at noon on the second day"
+	addr := startServer(t, map[string]string{
+		"xscevent/1": "From: kc6xyz@w6xsc.ampr.org\nTo: xscevent@allxsc\nSubject: " + subject + "\nDate: Mon, 12 Oct 2026 10:00:00 -0700\n\nBody.\n",
+	})
+	c := login(t, addr, "kc6aaa")
+	c.command("A xscevent")
+	out := c.command("L")
+	// JNOS lists the first 35 characters of the subject.
+	if !strings.Contains(out, " "+subject[:35]+"\r\n") {
+		t.Errorf("list: %q, want subject %q", out, subject[:35])
+	}
+}
+
+// TestConcurrentSessions lists messages in one session while another session
+// is reading (and so marking) them.  It is mostly useful under the race
+// detector.  The sessions are driven directly rather than over the network,
+// since network I/O would order their accesses for the race detector.
+func TestConcurrentSessions(t *testing.T) {
+	var (
+		wg    sync.WaitGroup
+		files = make(map[string]string)
+		nums  []string
+	)
+	for i := 1; i <= 20; i++ {
+		files[fmt.Sprintf("xscevent/%02d", i)] = fmt.Sprintf("From: kc6xyz@w6xsc.ampr.org\nTo: xscevent@allxsc\nSubject: XYZ-%03dP_R_Test\n\nBody.\n", i)
+		nums = append(nums, strconv.Itoa(i))
+	}
+	s := newServer(t, files)
+	reader := &session{s: s, user: "kc6aaa", w: bufio.NewWriter(io.Discard)}
+	lister := &session{s: s, user: "kc6bbb", w: bufio.NewWriter(io.Discard)}
+	reader.setArea("xscevent")
+	lister.setArea("xscevent")
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			reader.cmdRead(nums)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 50; i++ {
+			lister.cmdList("")
+		}
+	}()
+	wg.Wait()
+}